@@ -1,65 +1,134 @@
 package tiauth
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"os"
 	"strings"
-	"text/template"
 	"time"
 
 	"github.com/faroedev/faroe"
+	"github.com/tiptenbrink/tiauth-faroe/tiauth/throttle"
 )
 
-// ActionLogger implements faroe's action logging interface
-type ActionLogger struct{}
+// ActionLogger implements faroe's action logging interface. requestIDs is nil-safe
+// (empty correlation) so a bare &ActionLogger{} still works for tests/callers that
+// don't care about request correlation - see actionLoggerRequestIDs in access_log.go.
+type ActionLogger struct {
+	requestIDs *actionLoggerRequestIDs
+}
 
-func (*ActionLogger) LogActionError(timestamp time.Time, message string, actionInvocationId string, action string) {
-	log.Printf("[%s] action=%s (id %s) - %s", timestamp.Format("2006-01-02 15:04:05.000"), action, actionInvocationId, message)
+func (logger *ActionLogger) LogActionError(timestamp time.Time, message string, actionInvocationId string, action string) {
+	requestID := ""
+	if logger.requestIDs != nil {
+		requestID = logger.requestIDs.get()
+	}
+	if requestID == "" {
+		log.Printf("[%s] action=%s (id %s) - %s", timestamp.Format("2006-01-02 15:04:05.000"), action, actionInvocationId, message)
+		return
+	}
+	log.Printf("[%s] action=%s (id %s) request_id=%s - %s", timestamp.Format("2006-01-02 15:04:05.000"), action, actionInvocationId, requestID, message)
 }
 
 // App represents the running tiauth-faroe application
 type App struct {
-	config           Config
-	storage          *storageStruct
-	emailSender      *smtpActionsEmailSender
-	tokenBroadcaster *TokenBroadcaster
-	httpServer       *httpServer
-	shell            *interactiveShell
+	config             Config
+	storage            Storage
+	emailSender        faroe.EmailSenderInterface
+	emailActionsSender *actionsEmailSender // non-nil for the smtp/mailgun/ses/webhook backends
+	tokenBroadcaster   *TokenBroadcaster
+	httpServer         *httpServer
+	shell              *interactiveShell
 }
 
 // Run starts the tiauth-faroe server with the given configuration.
 // This is a blocking call that runs until an error occurs.
 func Run(cfg Config) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
 	app := &App{config: cfg}
 
 	// Validate required config
 	if cfg.UserActionInvocationURL == "" {
 		return fmt.Errorf("config error: UserActionInvocationURL is required (set FAROE_USER_ACTION_INVOCATION_URL in env file or environment)")
 	}
+	if cfg.EnableReset && (cfg.AdminUsername == "" || cfg.AdminPasswordHash == "") {
+		return fmt.Errorf("config error: AdminUsername and AdminPasswordHash are required when EnableReset is set (set FAROE_ADMIN_USERNAME and FAROE_ADMIN_PASSWORD_HASH; generate the hash with `tiauth hash-password`)")
+	}
+	if cfg.LogFormat != "text" && cfg.LogFormat != "json" {
+		return fmt.Errorf("config error: unknown LogFormat %q (must be \"text\" or \"json\")", cfg.LogFormat)
+	}
 
-	// Determine if SMTP should be used
-	smtpEnabled := !cfg.DisableSMTP
+	// Determine which email backend to use
+	smtpEnabled := cfg.EmailBackend == "smtp"
 	if smtpEnabled {
 		// Validate SMTP config only if SMTP is enabled
 		if cfg.SMTPSenderEmail == "" {
-			return fmt.Errorf("config error: SMTPSenderEmail is required when SMTP is enabled (set FAROE_SMTP_SENDER_EMAIL or use --no-smtp)")
+			return fmt.Errorf("config error: SMTPSenderEmail is required when EmailBackend is \"smtp\" (set FAROE_SMTP_SENDER_EMAIL)")
 		}
 		if cfg.SMTPServerHost == "" {
-			return fmt.Errorf("config error: SMTPServerHost is required when SMTP is enabled (set FAROE_SMTP_SERVER_HOST or use --no-smtp)")
+			return fmt.Errorf("config error: SMTPServerHost is required when EmailBackend is \"smtp\" (set FAROE_SMTP_SERVER_HOST)")
 		}
 		if cfg.SMTPServerPort == "" {
-			return fmt.Errorf("config error: SMTPServerPort is required when SMTP is enabled (set FAROE_SMTP_SERVER_PORT or use --no-smtp)")
+			return fmt.Errorf("config error: SMTPServerPort is required when EmailBackend is \"smtp\" (set FAROE_SMTP_SERVER_PORT)")
 		}
 		if cfg.SMTPDomain == "" {
-			return fmt.Errorf("config error: SMTPDomain is required when SMTP is enabled (set FAROE_SMTP_DOMAIN or use --no-smtp)")
+			return fmt.Errorf("config error: SMTPDomain is required when EmailBackend is \"smtp\" (set FAROE_SMTP_DOMAIN)")
+		}
+		if cfg.SMTPDKIMKeyPath != "" {
+			if cfg.SMTPDKIMSelector == "" {
+				return fmt.Errorf("config error: SMTPDKIMSelector is required when FAROE_SMTP_DKIM_KEY_PATH is set (set FAROE_SMTP_DKIM_SELECTOR)")
+			}
+			if cfg.SMTPDKIMDomain == "" {
+				return fmt.Errorf("config error: SMTPDKIMDomain is required when FAROE_SMTP_DKIM_KEY_PATH is set (set FAROE_SMTP_DKIM_DOMAIN)")
+			}
+		}
+		passwordAuth := cfg.SMTPUsername != "" && strings.ToLower(cfg.SMTPAuthMethod) != "xoauth2"
+		if passwordAuth && strings.ToLower(cfg.SMTPTLSMode) == "none" && !cfg.SMTPAllowInsecureAuth {
+			return fmt.Errorf("config error: refusing to send SMTP credentials over FAROE_SMTP_TLS_MODE \"none\" (set FAROE_SMTP_ALLOW_INSECURE_AUTH=true to override)")
+		}
+	} else if cfg.EmailBackend == "python" {
+		if cfg.PrivatePort == 0 {
+			return fmt.Errorf("config error: PrivatePort is required when EmailBackend is \"python\"")
 		}
-	} else {
-		log.Println("SMTP disabled - emails will not be sent, only tokens will be broadcast")
+	} else if cfg.EmailBackend == "mailgun" {
+		if cfg.MailgunAPIKey == "" {
+			return fmt.Errorf("config error: MailgunAPIKey is required when EmailBackend is \"mailgun\" (set FAROE_MAILGUN_API_KEY)")
+		}
+		if cfg.MailgunDomain == "" {
+			return fmt.Errorf("config error: MailgunDomain is required when EmailBackend is \"mailgun\" (set FAROE_MAILGUN_DOMAIN)")
+		}
+	} else if cfg.EmailBackend == "ses" {
+		if cfg.SESRegion == "" {
+			return fmt.Errorf("config error: SESRegion is required when EmailBackend is \"ses\" (set FAROE_SES_REGION)")
+		}
+		if cfg.SESAccessKeyID == "" {
+			return fmt.Errorf("config error: SESAccessKeyID is required when EmailBackend is \"ses\" (set FAROE_SES_ACCESS_KEY_ID)")
+		}
+		if cfg.SESSecretAccessKey == "" {
+			return fmt.Errorf("config error: SESSecretAccessKey is required when EmailBackend is \"ses\" (set FAROE_SES_SECRET_ACCESS_KEY)")
+		}
+	} else if cfg.EmailBackend == "webhook" {
+		// No required fields - an empty WebhookURL just means stdout-dump mode.
+	} else if cfg.EmailBackend == "push" {
+		if cfg.PushURL == "" {
+			return fmt.Errorf("config error: PushURL is required when EmailBackend is \"push\" (set FAROE_PUSH_URL)")
+		}
+	} else if cfg.EmailBackend != "stdout" {
+		return fmt.Errorf("config error: unknown EmailBackend %q (must be \"smtp\", \"python\", \"mailgun\", \"ses\", \"webhook\", \"push\", or \"stdout\")", cfg.EmailBackend)
+	}
+	if cfg.EmailBackend == "stdout" {
+		log.Println("Email backend is \"stdout\" - emails will not be sent, only tokens will be broadcast")
 	}
 
 	// Initialize storage
-	app.storage = newStorage(cfg.DBPath)
+	storage, err := NewStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize storage: %v", err)
+	}
+	app.storage = storage
 	defer app.storage.Close()
 
 	// Load private route access key if configured
@@ -77,8 +146,41 @@ func Run(cfg Config) error {
 	userActionInvocationClient := newUserActionInvocationClient(cfg.UserActionInvocationURL, privateRouteAccessKey)
 	userServerClient := faroe.NewUserServerClient(userActionInvocationClient)
 
-	// Initialize password hash algorithms
-	userPasswordHashAlgorithm := newArgon2id(3, 1024*64, 1)
+	// Initialize password hash algorithms. faroe matches a stored hash to an algorithm
+	// by an exact Id() string and always hashes *new* passwords with the first entry
+	// of the slice passed to NewServer below - so pointing Argon2TimeCost/MemoryKiB at
+	// stronger params (see `tiauth calibrate-argon2id`) migrates every new
+	// signup/password-change/password-reset to them automatically, without needing a
+	// rehash-on-verify hook (faroe's PasswordHashAlgorithmInterface has none). The
+	// original fixed params (3, 64*1024), plus every generation in
+	// Argon2LegacyParams, are kept registered as verify-only entries so hashes stored
+	// under any of them - including from before this config existed, or from an
+	// earlier recalibration - keep authenticating; none of them is ever used to hash
+	// anything new.
+	timeCost := cfg.Argon2TimeCost
+	if timeCost <= 0 {
+		timeCost = 3
+	}
+	memoryKiB := cfg.Argon2MemoryKiB
+	if memoryKiB <= 0 {
+		memoryKiB = 64 * 1024
+	}
+	userPasswordHashAlgorithm := newArgon2id(uint32(timeCost), uint32(memoryKiB), 1)
+	userPasswordHashAlgorithms := []faroe.PasswordHashAlgorithmInterface{userPasswordHashAlgorithm}
+
+	legacyParams := append([]string{"3:65536"}, cfg.Argon2LegacyParams...)
+	seenIds := map[string]bool{userPasswordHashAlgorithm.Id(): true}
+	for _, param := range legacyParams {
+		legacyAlgorithm, err := parseArgon2idLegacyParam(param)
+		if err != nil {
+			log.Fatalf("Invalid Argon2LegacyParams entry: %v", err)
+		}
+		if seenIds[legacyAlgorithm.Id()] {
+			continue
+		}
+		seenIds[legacyAlgorithm.Id()] = true
+		userPasswordHashAlgorithms = append(userPasswordHashAlgorithms, legacyAlgorithm)
+	}
 	temporaryPasswordHashAlgorithm := newArgon2id(3, 1024*16, 1)
 
 	// Initialize token broadcaster
@@ -89,58 +191,157 @@ func Run(cfg Config) error {
 	defer app.tokenBroadcaster.Close()
 
 	// Initialize email sender
-	if smtpEnabled {
-		// Determine SMTP security
-		var security smtpSecurity
-		if cfg.InsecureSMTP {
-			security = smtpInsecureDangerous
-		} else {
-			security = smtpSecure
+	switch cfg.EmailBackend {
+	case "smtp":
+		tlsMode := smtpTLSSTARTTLS
+		if cfg.SMTPTLSMode != "" {
+			tlsMode, err = parseSMTPTLSMode(cfg.SMTPTLSMode)
+			if err != nil {
+				return fmt.Errorf("invalid FAROE_SMTP_TLS_MODE: %v", err)
+			}
 		}
 
-		// Create email config
-		emailConfig := &smtpConfig{
-			senderName:       cfg.SMTPSenderName,
-			senderEmail:      cfg.SMTPSenderEmail,
-			serverHost:       cfg.SMTPServerHost,
-			serverPort:       cfg.SMTPServerPort,
-			ipVersion:        ipv4,
-			domain:           cfg.SMTPDomain,
-			security:         security,
-			disableKeepAlive: cfg.NoKeepAlive,
-			templatesPath:    cfg.EmailTemplatesPath,
-		}
-
-		// Load email templates if path is provided
-		var templates *template.Template
-		if cfg.EmailTemplatesPath != "" {
-			var err error
-			templates, err = loadEmailTemplates(cfg.EmailTemplatesPath)
+		smtpAuth, err := buildSMTPAuth(cfg)
+		if err != nil {
+			return fmt.Errorf("config error: %v", err)
+		}
+
+		var dkim *dkimSigner
+		if cfg.SMTPDKIMKeyPath != "" {
+			dkim, err = loadDKIMSigner(cfg.SMTPDKIMKeyPath, cfg.SMTPDKIMSelector, cfg.SMTPDKIMDomain)
 			if err != nil {
-				return fmt.Errorf("failed to load email templates: %v", err)
+				return fmt.Errorf("config error: loading FAROE_SMTP_DKIM_KEY_PATH: %v", err)
 			}
-			log.Printf("Loaded email templates from %s", cfg.EmailTemplatesPath)
 		}
 
-		app.emailSender = &smtpActionsEmailSender{
-			config:           emailConfig,
+		// Create email config
+		emailConfig := &smtpConfig{
+			serverHost:           cfg.SMTPServerHost,
+			serverPort:           cfg.SMTPServerPort,
+			ipVersion:            ipv4,
+			domain:               cfg.SMTPDomain,
+			auth:                 smtpAuth,
+			tlsMode:              tlsMode,
+			disableKeepAlive:     cfg.NoKeepAlive,
+			connectionTimeout:    cfg.SMTPConnectionTimeout,
+			tcpKeepalivePeriod:   cfg.SMTPKeepAlivePeriod,
+			tcpKeepaliveProbes:   cfg.SMTPKeepAliveProbes,
+			tcpKeepaliveInterval: cfg.SMTPKeepAliveInterval,
+			maxAttempts:          cfg.SMTPMaxAttempts,
+			initialBackoff:       cfg.SMTPInitialBackoff,
+			maxBackoff:           cfg.SMTPMaxBackoff,
+			jitter:               cfg.SMTPJitter,
+			rateLimitPerDomain:   cfg.SMTPRateLimitPerDomain,
+			rateLimitBurst:       cfg.SMTPRateLimitBurst,
+			onError: func(err error) {
+				log.Printf("SMTP connection unhealthy: %v", err)
+			},
+			dkim: dkim,
+		}
+
+		templates, err := loadConfiguredTemplates(cfg)
+		if err != nil {
+			return err
+		}
+
+		emailActionsSender := &actionsEmailSender{
+			senderName:            cfg.SMTPSenderName,
+			senderEmail:           cfg.SMTPSenderEmail,
+			client:                newSMTPEmailClient(emailConfig),
+			templates:             templates,
+			tokenBroadcaster:      app.tokenBroadcaster,
+			unsubscribeURLBuilder: buildUnsubscribeURLBuilder(cfg.SMTPUnsubscribeURLTemplate),
+		}
+
+		noopInterval := cfg.SMTPNoopInterval
+		if noopInterval == 0 {
+			noopInterval = time.Minute * 5
+		}
+
+		if err := emailActionsSender.Start(noopInterval); err != nil {
+			return fmt.Errorf("failed to start email sender: %v", err)
+		}
+		defer emailActionsSender.Close()
+
+		app.emailActionsSender = emailActionsSender
+		app.emailSender = emailActionsSender
+	case "mailgun":
+		templates, err := loadConfiguredTemplates(cfg)
+		if err != nil {
+			return err
+		}
+
+		emailActionsSender := &actionsEmailSender{
+			senderName:  cfg.SMTPSenderName,
+			senderEmail: cfg.SMTPSenderEmail,
+			client: newMailgunEmailClient(mailgunConfig{
+				apiKey:         cfg.MailgunAPIKey,
+				domain:         cfg.MailgunDomain,
+				baseURL:        cfg.MailgunBaseURL,
+				requestTimeout: cfg.MailgunRequestTimeout,
+			}),
 			templates:        templates,
 			tokenBroadcaster: app.tokenBroadcaster,
 		}
 
-		app.emailSender.m.Lock()
-		err := app.emailSender.Start(time.Minute * 5)
+		app.emailActionsSender = emailActionsSender
+		app.emailSender = emailActionsSender
+	case "ses":
+		templates, err := loadConfiguredTemplates(cfg)
 		if err != nil {
-			app.emailSender.m.Unlock()
-			return fmt.Errorf("failed to start email sender: %v", err)
+			return err
 		}
-		app.emailSender.m.Unlock()
-		defer app.emailSender.Close()
-	} else {
-		// Create email sender that only broadcasts tokens (no SMTP)
-		app.emailSender = &smtpActionsEmailSender{
+
+		emailActionsSender := &actionsEmailSender{
+			senderName:  cfg.SMTPSenderName,
+			senderEmail: cfg.SMTPSenderEmail,
+			client: newSESEmailClient(sesConfig{
+				region:          cfg.SESRegion,
+				accessKeyID:     cfg.SESAccessKeyID,
+				secretAccessKey: cfg.SESSecretAccessKey,
+				sessionToken:    cfg.SESSessionToken,
+				requestTimeout:  cfg.SESRequestTimeout,
+			}),
+			templates:        templates,
+			tokenBroadcaster: app.tokenBroadcaster,
+		}
+
+		app.emailActionsSender = emailActionsSender
+		app.emailSender = emailActionsSender
+	case "webhook":
+		templates, err := loadConfiguredTemplates(cfg)
+		if err != nil {
+			return err
+		}
+
+		emailActionsSender := &actionsEmailSender{
+			senderName:  cfg.SMTPSenderName,
+			senderEmail: cfg.SMTPSenderEmail,
+			client: newWebhookEmailClient(webhookConfig{
+				url:            cfg.WebhookURL,
+				requestTimeout: cfg.WebhookRequestTimeout,
+			}),
+			templates:        templates,
 			tokenBroadcaster: app.tokenBroadcaster,
 		}
+
+		app.emailActionsSender = emailActionsSender
+		app.emailSender = emailActionsSender
+	case "python":
+		backendClient, err := NewBackendClient(ctx, cfg.PrivatePort, 0, cfg.TransportSecurity())
+		if err != nil {
+			return fmt.Errorf("failed to create backend client: %v", err)
+		}
+		app.emailSender = &backendEmailSender{backendClient: backendClient}
+	case "stdout":
+		app.emailSender = &stdoutEmailSender{tokenBroadcaster: app.tokenBroadcaster}
+	case "push":
+		app.emailSender = newPushNotifier(ntfyConfig{
+			topicURLTemplate: cfg.PushURL,
+			authUser:         cfg.PushAuthUser,
+			authPassword:     cfg.PushAuthPassword,
+			requestTimeout:   cfg.PushRequestTimeout,
+		}, app.tokenBroadcaster)
 	}
 
 	// Session expiration
@@ -149,12 +350,23 @@ func Run(cfg Config) error {
 		sessionExpiration = 90 * 24 * time.Hour
 	}
 
+	// Initialize access logging. actionLoggerRequestIDs is shared between the
+	// *ActionLogger passed to faroe.NewServer below and the httpServer created
+	// further down, so an action error logged mid-invocation can be correlated back
+	// to the HTTP request that triggered it - see access_log.go.
+	actionLoggerRequestIDs := newActionLoggerRequestIDs()
+	accessLog, err := newAccessLogger(cfg.AccessLogPath, cfg.LogFormat)
+	if err != nil {
+		return fmt.Errorf("failed to open access log: %v", err)
+	}
+	accessLog.watchReopen()
+
 	// Create faroe server
 	faroeServer := faroe.NewServer(
 		app.storage,
 		userServerClient,
-		&ActionLogger{},
-		[]faroe.PasswordHashAlgorithmInterface{userPasswordHashAlgorithm},
+		&ActionLogger{requestIDs: actionLoggerRequestIDs},
+		userPasswordHashAlgorithms,
 		temporaryPasswordHashAlgorithm,
 		1,
 		faroe.RealClock,
@@ -167,39 +379,88 @@ func Run(cfg Config) error {
 		},
 	)
 
+	// Throttle password/verification-code attempts before they reach faroe.
+	loginThrottler := throttle.New(cfg.LoginThrottleMax, cfg.LoginThrottleWindow, cfg.LoginThrottleCap)
+	loginThrottler.Start(cfg.LoginThrottleWindow)
+	defer loginThrottler.Stop()
+
+	// Throttle password reset requests (cooldown + per-day quota) before they reach faroe.
+	mainStorage, err := NewMainStorage(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to initialize main storage: %v", err)
+	}
+	defer mainStorage.Close()
+	resetThrottle := newPasswordResetThrottle(mainStorage, cfg.PasswordResetCooldown, cfg.PasswordResetDailyMax, app.tokenBroadcaster)
+
+	var emailHealth emailClientHealthChecker
+	if app.emailActionsSender != nil {
+		emailHealth, _ = app.emailActionsSender.client.(emailClientHealthChecker)
+	}
+
 	// Start HTTP server
 	app.httpServer = &httpServer{
-		server:          faroeServer,
-		storage:         app.storage,
-		enableReset:     cfg.EnableReset,
-		corsAllowOrigin: cfg.CORSAllowOrigin,
+		server:                 faroeServer,
+		storage:                app.storage,
+		enableReset:            cfg.EnableReset,
+		adminUsername:          cfg.AdminUsername,
+		adminPasswordHash:      cfg.AdminPasswordHash,
+		corsAllowOrigin:        cfg.CORSAllowOrigin,
+		throttler:              loginThrottler,
+		passwordResetThrottle:  resetThrottle,
+		emailHealth:            emailHealth,
+		accessLog:              accessLog,
+		actionLoggerRequestIDs: actionLoggerRequestIDs,
 	}
 	app.httpServer.listen(cfg.Port)
+	if cfg.TLSCertFile != "" || cfg.TLSKeyFile != "" {
+		if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+			return fmt.Errorf("config error: both TLSCertFile and TLSKeyFile are required to enable HTTPS (set FAROE_TLS_CERT_FILE and FAROE_TLS_KEY_FILE)")
+		}
+		if err := app.httpServer.listenTLS(cfg.TLSCertFile, cfg.TLSKeyFile, cfg.TLSListenPort, cfg.TLSAutoReloadInterval); err != nil {
+			return fmt.Errorf("config error: %v", err)
+		}
+	}
 
 	// Start interactive shell if enabled
-	app.shell = newInteractiveShell(app.storage)
+	app.shell = newInteractiveShell(app.storage, faroeServer, userServerClient, app.tokenBroadcaster, app.emailActionsSender, loginThrottler)
 	if cfg.EnableInteractive {
-		app.shell.listen()
+		app.shell.listen(ctx)
 	}
 
-	// Wait for errors
+	// Wait for errors. mailErrChan is nil for the python/stdout backends (and for any
+	// EmailClient that doesn't report async failures) - receiving from a nil channel
+	// blocks forever, so it's safe to always include in the select.
+	var mailErrChan <-chan error
+	if app.emailActionsSender != nil {
+		mailErrChan = app.emailActionsSender.ErrChan()
+	}
 	for {
-		if smtpEnabled {
-			select {
-			case serverErr := <-app.httpServer.errChan:
-				return serverErr
-			case mailErr := <-app.emailSender.errChan:
-				return mailErr
-			case shellErr := <-app.shell.errChan:
-				return shellErr
-			}
-		} else {
-			select {
-			case serverErr := <-app.httpServer.errChan:
-				return serverErr
-			case shellErr := <-app.shell.errChan:
-				return shellErr
-			}
+		select {
+		case serverErr := <-app.httpServer.errChan:
+			return serverErr
+		case mailErr := <-mailErrChan:
+			return mailErr
+		case shellErr := <-app.shell.errChan:
+			return shellErr
+		}
+	}
+}
+
+// loadConfiguredTemplates builds the TemplateRenderer shared by every actionsEmailSender
+// backend: a configured directory reloads from disk on every send (dev mode),
+// otherwise it falls back to the templates embedded in the binary.
+func loadConfiguredTemplates(cfg Config) (TemplateRenderer, error) {
+	if cfg.EmailTemplatesPath != "" {
+		if _, err := os.Stat(cfg.EmailTemplatesPath); err != nil {
+			return nil, fmt.Errorf("failed to load email templates: %v", err)
 		}
+		log.Printf("Loading email templates from %s", cfg.EmailTemplatesPath)
+		return NewDirTemplateRenderer(cfg.EmailTemplatesPath), nil
+	}
+
+	templates, err := newDefaultTemplateRenderer()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load embedded email templates: %v", err)
 	}
+	return templates, nil
 }
@@ -2,6 +2,9 @@ package tiauth
 
 import (
 	"fmt"
+	"strconv"
+	"strings"
+	"time"
 
 	"golang.org/x/crypto/argon2"
 )
@@ -22,10 +25,81 @@ func newArgon2id(time uint32, memory uint32, threads uint8) *argon2idStruct {
 	return argon2id
 }
 
+// calibrationPasswordProbe is hashed repeatedly to measure how long this host takes
+// to run argon2id at a given set of parameters. Its content is irrelevant.
+const calibrationPasswordProbe = "tiauth-argon2id-calibration-probe"
+
+// maxCalibrationTimeCost bounds how high the doubling search in CalibrateArgon2id is
+// allowed to drive the argon2id time cost, so a slow host can't calibrate forever.
+const maxCalibrationTimeCost = 64
+
+// CalibrateArgon2id measures argon2id hashing time on this host and picks the time
+// cost that makes a single hash take approximately targetDuration, at a fixed memory
+// cost of maxMemoryKiB (defaulted to 64 MiB when zero or negative) and a single
+// thread. This is a one-shot measurement for an operator to run via `tiauth
+// calibrate-argon2id` and paste the result into Config.Argon2TimeCost/MemoryKiB - it
+// is deliberately not invoked automatically by Run (see app.go), since timing jitter
+// between runs could otherwise mint a different id on every restart and strand
+// previously stored hashes.
+func CalibrateArgon2id(targetDuration time.Duration, maxMemoryKiB int) *argon2idStruct {
+	memory := uint32(64 * 1024)
+	if maxMemoryKiB > 0 {
+		memory = uint32(maxMemoryKiB)
+	}
+	threads := uint8(1)
+	salt := make([]byte, 16)
+
+	costTime := uint32(1)
+	for {
+		start := time.Now()
+		argon2.IDKey([]byte(calibrationPasswordProbe), salt, costTime, memory, threads, 32)
+		elapsed := time.Since(start)
+
+		if elapsed >= targetDuration || costTime >= maxCalibrationTimeCost {
+			break
+		}
+		costTime *= 2
+	}
+
+	return newArgon2id(costTime, memory, threads)
+}
+
+// parseArgon2idLegacyParam parses one "timeCost:memoryKiB" entry from
+// Config.Argon2LegacyParams (see Run in app.go) into a single-thread argon2id
+// algorithm, matching how Run builds the current one from Argon2TimeCost/MemoryKiB.
+func parseArgon2idLegacyParam(param string) (*argon2idStruct, error) {
+	timeStr, memoryStr, ok := strings.Cut(param, ":")
+	if !ok {
+		return nil, fmt.Errorf("expected \"timeCost:memoryKiB\", got %q", param)
+	}
+
+	timeCost, err := strconv.ParseUint(strings.TrimSpace(timeStr), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time cost in %q: %w", param, err)
+	}
+	memoryKiB, err := strconv.ParseUint(strings.TrimSpace(memoryStr), 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("invalid memory cost in %q: %w", param, err)
+	}
+
+	return newArgon2id(uint32(timeCost), uint32(memoryKiB), 1), nil
+}
+
 func (argon2id *argon2idStruct) Id() string {
 	return fmt.Sprintf("argon2id.%d.%d.%d.32", argon2id.time, argon2id.memory, argon2id.threads)
 }
 
+// TimeCost and MemoryKiB expose the params CalibrateArgon2id picked, so callers like
+// `tiauth calibrate-argon2id` can print them as FAROE_ARGON2_TIME_COST/MEMORY_KIB
+// without parsing Id().
+func (argon2id *argon2idStruct) TimeCost() uint32 {
+	return argon2id.time
+}
+
+func (argon2id *argon2idStruct) MemoryKiB() uint32 {
+	return argon2id.memory
+}
+
 func (*argon2idStruct) SaltSize() int {
 	return 16
 }
@@ -2,38 +2,106 @@ package tiauth
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"time"
 )
 
 // PrivateHost is the loopback address used for the private server.
 // Uses 127.0.0.2 for isolation from the main loopback (127.0.0.1).
 const PrivateHost = "127.0.0.2"
 
+// defaultRequestTimeout bounds a single outbound call when no read/write deadline
+// has been set and the parent context carries no deadline of its own.
+const defaultRequestTimeout = 30 * time.Second
+
 // BackendClient provides HTTP communication with the Python backend's private server.
 type BackendClient struct {
-	baseURL string
-	client  *http.Client
+	baseURL  string
+	client   *http.Client
+	ctx      context.Context
+	timeout  time.Duration
+	security TransportSecurity
+
+	readDeadline  *deadlineTimer
+	writeDeadline *deadlineTimer
 }
 
-// NewBackendClient creates a new HTTP client for the Python backend.
-func NewBackendClient(port int) *BackendClient {
+// NewBackendClient creates a new HTTP client for the Python backend. ctx is the parent
+// shutdown context: once it's canceled, in-flight and future requests are aborted.
+// timeout bounds each individual request and defaults to defaultRequestTimeout when zero.
+// security configures mTLS and/or HMAC request signing; its zero value disables both.
+func NewBackendClient(ctx context.Context, port int, timeout time.Duration, security TransportSecurity) (*BackendClient, error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+
+	scheme := "http"
+	client := &http.Client{}
+	if security.tlsEnabled() {
+		tlsConfig, err := security.tlsConfig()
+		if err != nil {
+			return nil, fmt.Errorf("failed to configure mTLS: %v", err)
+		}
+		client.Transport = &http.Transport{TLSClientConfig: tlsConfig}
+		scheme = "https"
+	}
+
 	return &BackendClient{
-		baseURL: fmt.Sprintf("http://%s:%d", PrivateHost, port),
-		client:  &http.Client{},
+		baseURL:       fmt.Sprintf("%s://%s:%d", scheme, PrivateHost, port),
+		client:        client,
+		ctx:           ctx,
+		timeout:       timeout,
+		security:      security,
+		readDeadline:  newDeadlineTimer(),
+		writeDeadline: newDeadlineTimer(),
+	}, nil
+}
+
+// SetReadDeadline bounds the time a caller is willing to wait for a response body to
+// be read. It applies to the next call(s) until a new deadline is set.
+func (c *BackendClient) SetReadDeadline(t time.Time) {
+	c.readDeadline.set(t)
+}
+
+// SetWriteDeadline bounds the time a caller is willing to wait for a request to be sent.
+// It applies to the next call(s) until a new deadline is set.
+func (c *BackendClient) SetWriteDeadline(t time.Time) {
+	c.writeDeadline.set(t)
+}
+
+// requestContext derives a context bounded by c.timeout, c.ctx's cancellation, and the
+// most recently set read/write deadlines.
+func (c *BackendClient) requestContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeout)
+	ctx, cancelWrite := withCancelCh(ctx, c.writeDeadline.get())
+	ctx, cancelRead := withCancelCh(ctx, c.readDeadline.get())
+
+	return ctx, func() {
+		cancelRead()
+		cancelWrite()
+		cancel()
 	}
 }
 
 // SendActionInvocationEndpointRequest implements faroe's ActionInvocationEndpointClientInterface
 // by sending JSON requests to Python's /invoke endpoint.
 func (c *BackendClient) SendActionInvocationEndpointRequest(requestJSON string) (string, error) {
-	req, err := http.NewRequest("POST", c.baseURL+"/invoke", bytes.NewReader([]byte(requestJSON)))
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/invoke", bytes.NewReader([]byte(requestJSON)))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	c.security.applySignature(req, "POST", "/invoke", requestJSON)
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -66,16 +134,20 @@ type EmailRequest struct {
 // SendEmail sends an email request to Python's /email endpoint.
 // The Python backend handles token storage and SMTP delivery.
 func (c *BackendClient) SendEmail(req EmailRequest) error {
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
 	jsonData, err := json.Marshal(req)
 	if err != nil {
 		return fmt.Errorf("failed to marshal email request: %w", err)
 	}
 
-	httpReq, err := http.NewRequest("POST", c.baseURL+"/email", bytes.NewReader(jsonData))
+	httpReq, err := http.NewRequestWithContext(ctx, "POST", c.baseURL+"/email", bytes.NewReader(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create email request: %w", err)
 	}
 	httpReq.Header.Set("Content-Type", "application/json")
+	c.security.applySignature(httpReq, "POST", "/email", string(jsonData))
 
 	resp, err := c.client.Do(httpReq)
 	if err != nil {
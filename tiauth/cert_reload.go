@@ -0,0 +1,90 @@
+package tiauth
+
+import (
+	"crypto/tls"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// certReloader serves a TLS certificate loaded from certFile/keyFile via
+// tls.Config.GetCertificate, re-stat'ing both files on watch's interval and
+// hot-swapping the loaded certificate when either's mtime changes - so a
+// certbot-style renewal in place is picked up without restarting the process.
+type certReloader struct {
+	certFile string
+	keyFile  string
+
+	mu          sync.RWMutex
+	cert        *tls.Certificate
+	certModTime time.Time
+	keyModTime  time.Time
+}
+
+// newCertReloader loads certFile/keyFile once up front, so a startup misconfiguration
+// (missing file, mismatched key) fails immediately rather than on the first request.
+func newCertReloader(certFile, keyFile string) (*certReloader, error) {
+	reloader := &certReloader{certFile: certFile, keyFile: keyFile}
+	if err := reloader.reload(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// reload re-stats certFile/keyFile and reloads the key pair only if either mtime has
+// changed since the last successful load.
+func (reloader *certReloader) reload() error {
+	certInfo, err := os.Stat(reloader.certFile)
+	if err != nil {
+		return fmt.Errorf("stat TLS cert file: %v", err)
+	}
+	keyInfo, err := os.Stat(reloader.keyFile)
+	if err != nil {
+		return fmt.Errorf("stat TLS key file: %v", err)
+	}
+
+	reloader.mu.RLock()
+	unchanged := reloader.cert != nil &&
+		certInfo.ModTime().Equal(reloader.certModTime) &&
+		keyInfo.ModTime().Equal(reloader.keyModTime)
+	reloader.mu.RUnlock()
+	if unchanged {
+		return nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+	if err != nil {
+		return fmt.Errorf("load TLS key pair: %v", err)
+	}
+
+	reloader.mu.Lock()
+	reloader.cert = &cert
+	reloader.certModTime = certInfo.ModTime()
+	reloader.keyModTime = keyInfo.ModTime()
+	reloader.mu.Unlock()
+
+	return nil
+}
+
+// getCertificate is a tls.Config.GetCertificate callback serving the most recently
+// loaded certificate.
+func (reloader *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.mu.RLock()
+	defer reloader.mu.RUnlock()
+	return reloader.cert, nil
+}
+
+// watch re-stats the cert/key files every interval, logging (rather than failing) a
+// reload error so a read racing a renewal tool mid-write doesn't take the listener
+// down - the previously loaded certificate keeps serving until a clean reload succeeds.
+func (reloader *certReloader) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for range ticker.C {
+		if err := reloader.reload(); err != nil {
+			log.Printf("TLS cert reload failed, keeping previous certificate: %v", err)
+		}
+	}
+}
@@ -1,18 +1,164 @@
 package main
 
 import (
+	"flag"
+	"fmt"
 	"log"
+	"os"
+	"time"
 
 	"github.com/tiptenbrink/tiauth-faroe/tiauth"
+	"golang.org/x/term"
 )
 
 func main() {
-	cfg, err := tiauth.ParseFlagsAndConfig()
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	switch os.Args[1] {
+	case "serve":
+		runServe(os.Args[2:])
+	case "export":
+		runExport(os.Args[2:])
+	case "import":
+		runImport(os.Args[2:])
+	case "hash-password":
+		runHashPassword(os.Args[2:])
+	case "calibrate-argon2id":
+		runCalibrateArgon2id(os.Args[2:])
+	case "defaultconfig":
+		fmt.Print(tiauth.DefaultEnvTemplate())
+	case "-h", "--help", "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "tiauth: unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+}
+
+func usage() {
+	fmt.Fprint(os.Stderr, `Usage: tiauth <command> [flags]
+
+Commands:
+  serve               Run the tiauth-faroe server
+  export              Export storage entries to a JSONL file
+  import              Import storage entries from a JSONL file
+  hash-password       Hash a password for FAROE_ADMIN_PASSWORD_HASH
+  calibrate-argon2id  Measure argon2id params for FAROE_ARGON2_TIME_COST/MEMORY_KIB
+  defaultconfig       Print the embedded default .env template
+
+Run "tiauth <command> -h" for a command's own flags.
+`)
+}
+
+// runServe keeps today's behavior (load config from an env file plus flag overrides,
+// then block running the server) but on its own FlagSet instead of flag.CommandLine,
+// so "tiauth serve -h" only lists serve's flags.
+func runServe(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	f := tiauth.RegisterFlags(fs)
+	fs.Parse(args)
+
+	cfg, err := tiauth.ConfigFromFlags(f)
 	if err != nil {
 		log.Fatalf("Failed to load config: %v", err)
 	}
-
 	if err := tiauth.Run(cfg); err != nil {
 		log.Fatal(err)
 	}
 }
+
+// runExport and runImport share a storage backend's worth of config: an env file to
+// load StorageBackend/DBPath etc. from, and the JSONL path to read/write.
+func runExport(args []string) {
+	fs := flag.NewFlagSet("export", flag.ExitOnError)
+	envFile := fs.String("env-file", ".env", "Path to environment file")
+	out := fs.String("out", "", "Path to write exported storage entries to (required)")
+	fs.Parse(args)
+	if *out == "" {
+		fmt.Fprintln(os.Stderr, "tiauth export: -out is required")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := tiauth.ConfigFromEnv(*envFile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	storage, err := tiauth.NewStorage(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer storage.Close()
+
+	if err := tiauth.ExportStorage(storage, *out); err != nil {
+		log.Fatalf("Export failed: %v", err)
+	}
+	fmt.Printf("Exported storage entries to %s\n", *out)
+}
+
+func runImport(args []string) {
+	fs := flag.NewFlagSet("import", flag.ExitOnError)
+	envFile := fs.String("env-file", ".env", "Path to environment file")
+	in := fs.String("in", "", "Path to read storage entries from (required)")
+	fs.Parse(args)
+	if *in == "" {
+		fmt.Fprintln(os.Stderr, "tiauth import: -in is required")
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	cfg, err := tiauth.ConfigFromEnv(*envFile)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	storage, err := tiauth.NewStorage(cfg)
+	if err != nil {
+		log.Fatalf("Failed to open storage: %v", err)
+	}
+	defer storage.Close()
+
+	if err := tiauth.ImportStorage(storage, *in); err != nil {
+		log.Fatalf("Import failed: %v", err)
+	}
+	fmt.Printf("Imported storage entries from %s\n", *in)
+}
+
+// runHashPassword prompts for a password with the terminal echo disabled (so it
+// doesn't land in scrollback or a recorded terminal session) and prints the resulting
+// bcrypt hash for FAROE_ADMIN_PASSWORD_HASH.
+func runHashPassword(args []string) {
+	fs := flag.NewFlagSet("hash-password", flag.ExitOnError)
+	fs.Parse(args)
+
+	fmt.Fprint(os.Stderr, "Password: ")
+	passwordBytes, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		log.Fatalf("Failed to read password: %v", err)
+	}
+
+	hash, err := tiauth.HashPassword(string(passwordBytes))
+	if err != nil {
+		log.Fatalf("Failed to hash password: %v", err)
+	}
+	fmt.Println(hash)
+}
+
+// runCalibrateArgon2id measures how long argon2id takes on this host and prints the
+// env vars to set so `serve` hashes new passwords with those params - a one-shot,
+// operator-run step rather than something serve measures on every startup (see
+// CalibrateArgon2id in argon2id.go for why).
+func runCalibrateArgon2id(args []string) {
+	fs := flag.NewFlagSet("calibrate-argon2id", flag.ExitOnError)
+	target := fs.Duration("target", 100*time.Millisecond, "Target duration for a single hash")
+	maxMemoryKiB := fs.Int("max-memory-kib", 0, "Memory cost in KiB (defaults to 64 MiB)")
+	fs.Parse(args)
+
+	algorithm := tiauth.CalibrateArgon2id(*target, *maxMemoryKiB)
+	fmt.Printf("FAROE_ARGON2_TIME_COST=%d\n", algorithm.TimeCost())
+	fmt.Printf("FAROE_ARGON2_MEMORY_KIB=%d\n", algorithm.MemoryKiB())
+}
@@ -3,20 +3,68 @@ package tiauth
 import (
 	"bufio"
 	"flag"
+	"fmt"
+	"log"
 	"os"
 	"strconv"
 	"strings"
 	"time"
+
+	"golang.org/x/crypto/bcrypt"
 )
 
 // Config holds all configuration for the tiauth-faroe server
 type Config struct {
 	// Database path for SQLite storage
 	DBPath string
+	// Database path for the SQLite main storage backend (used when StorageBackend is "sqlite3")
+	MainDBPath string
 	// Port to listen on
 	Port string
+
+	// TLSCertFile, TLSKeyFile configure an additional HTTPS listener alongside the
+	// plaintext one on Port - both must be set to enable it. TLSListenPort defaults
+	// to "443" when empty. TLSAutoReloadInterval controls how often the cert/key
+	// files are re-stat'd for a changed mtime (e.g. after a certbot renewal) and
+	// hot-swapped without a restart; defaults to 1 minute when zero.
+	TLSCertFile           string
+	TLSKeyFile            string
+	TLSListenPort         string
+	TLSAutoReloadInterval time.Duration
+
+	// StorageBackend selects the key/value backend: "sqlite3" (default), "etcd", or "postgres"
+	StorageBackend string
+	// PostgresDSN is the connection string used when StorageBackend is "postgres"
+	PostgresDSN string
+	// EtcdEndpoints lists the etcd cluster members used when StorageBackend is "etcd"
+	EtcdEndpoints []string
+	// EtcdNamespace prefixes every key stored in etcd
+	EtcdNamespace string
+	// EtcdCAFile, EtcdCertFile, EtcdKeyFile, EtcdServerName configure TLS for the etcd client
+	EtcdCAFile     string
+	EtcdCertFile   string
+	EtcdKeyFile    string
+	EtcdServerName string
 	// Port for Python backend communication (binds to 127.0.0.2)
 	PrivatePort int
+	// URL of the Python backend's user action invocation endpoint
+	UserActionInvocationURL string
+	// Path to a file containing the private route access key sent to the Python backend
+	PrivateRouteKeyFile string
+	// Path to the Unix domain socket used to broadcast token events
+	TokenSocketPath string
+
+	// TransportTLSCAFile, TransportTLSCertFile, TransportTLSKeyFile, TransportTLSServerName
+	// configure mTLS for BackendClient's connection to the Python backend's private server.
+	TransportTLSCAFile     string
+	TransportTLSCertFile   string
+	TransportTLSKeyFile    string
+	TransportTLSServerName string
+	// TransportHMACSecret, when set, signs BackendClient/UDSClient requests with an
+	// x-tiauth-signature header. TransportReplayWindow bounds how old an accepted
+	// timestamp may be (defaults to 30s when zero).
+	TransportHMACSecret   string
+	TransportReplayWindow time.Duration
 
 	// Session expiration duration (default: 90 days)
 	SessionExpiration time.Duration
@@ -24,6 +72,197 @@ type Config struct {
 	// CORS allowed origin (specific origin like "https://example.com", empty to not set header)
 	CORSAllowOrigin string
 
+	// EmailBackend selects how outbound email is delivered: "smtp" (native Go SMTP
+	// client, default), "mailgun" (Mailgun HTTP API), "ses" (AWS SES v2 HTTP API),
+	// "webhook" (JSON-POST to a configured URL, or stdout if none is set), "push"
+	// (ntfy-compatible push notification instead of email - see PushURL), "python"
+	// (delegate to the Python backend over HTTP), or "stdout" (log rendered messages
+	// instead of sending, for local dev/testing).
+	EmailBackend string
+	// SMTP settings for the email sender (used when EmailBackend is "smtp")
+	DisableSMTP        bool
+	NoKeepAlive        bool
+	SMTPSenderName     string
+	SMTPSenderEmail    string
+	SMTPServerHost     string
+	SMTPServerPort     string
+	SMTPDomain         string
+	EmailTemplatesPath string
+
+	// SMTPTLSMode selects how the SMTP connection is secured: "starttls" (default -
+	// dial plaintext, upgrade via the STARTTLS command, the usual mode for port 587),
+	// "implicit" (wrap the connection in TLS before speaking SMTP at all, for port
+	// 465), or "none" (never enable TLS - only for testing against a local/trusted
+	// server).
+	SMTPTLSMode string
+
+	// SMTPAuthMethod selects how the SMTP sender authenticates: "plain" (default
+	// when SMTPUsername is set), "login", "crammd5", or "xoauth2". Empty/unset with
+	// no SMTPUsername means no authentication is performed.
+	SMTPAuthMethod string
+	// SMTPUsername, SMTPPassword are used when SMTPAuthMethod is "plain", "login",
+	// or "crammd5".
+	SMTPUsername string
+	SMTPPassword string
+	// SMTPAllowInsecureAuth must be set to send credentials (plain/login/crammd5)
+	// over an unencrypted link (SMTPTLSMode "none") - otherwise Run refuses to start
+	// rather than putting a password on the wire in the clear. Has no effect on
+	// "starttls"/"implicit", or on "xoauth2" (a token, not a password).
+	SMTPAllowInsecureAuth bool
+	// SMTPOAuthUsername is the mailbox to authenticate as for XOAUTH2 (the SASL
+	// "user=" field); defaults to SMTPSenderEmail when empty.
+	SMTPOAuthUsername string
+	// SMTPOAuthTokenURL, SMTPOAuthClientID, SMTPOAuthClientSecret, SMTPOAuthRefreshToken
+	// configure the OAuth2 refresh-token exchange used to mint access tokens when
+	// SMTPAuthMethod is "xoauth2".
+	SMTPOAuthTokenURL     string
+	SMTPOAuthClientID     string
+	SMTPOAuthClientSecret string
+	SMTPOAuthRefreshToken string
+
+	// SMTPConnectionTimeout bounds the initial TCP handshake to the SMTP server.
+	// 0 means no timeout.
+	SMTPConnectionTimeout time.Duration
+	// SMTPKeepAlivePeriod is how often the kernel sends TCP keepalive probes on an
+	// idle SMTP connection (net.Dialer.KeepAlive). Negative disables OS keepalive.
+	SMTPKeepAlivePeriod time.Duration
+	// SMTPKeepAliveProbes and SMTPKeepAliveInterval set TCP_KEEPCNT/TCP_KEEPINTVL on
+	// the connected socket (Linux only, see smtp_keepalive_linux.go), for detecting a
+	// dead connection through an idle-killing NAT/load balancer faster than the OS
+	// default. 0 leaves the OS default for that setting untouched.
+	SMTPKeepAliveProbes   int
+	SMTPKeepAliveInterval time.Duration
+	// SMTPNoopInterval is how often smtpEmailClient pings an idle SMTP connection
+	// with a NOOP to keep it alive at the application level.
+	SMTPNoopInterval time.Duration
+	// SMTPMaxAttempts bounds how many times smtpEmailClient retries delivery to one
+	// recipient before giving up. A 5xx SMTP reply aborts immediately regardless of
+	// this setting. Zero defaults to 3.
+	SMTPMaxAttempts int
+	// SMTPInitialBackoff is the delay before the second delivery attempt; each
+	// further retry doubles it, capped at SMTPMaxBackoff. Zero disables the delay
+	// (retries immediately). SMTPJitter adds up to a random extra duration on top
+	// of each computed delay, so recipients failing against the same downstream
+	// server don't all retry in lockstep.
+	SMTPInitialBackoff time.Duration
+	SMTPMaxBackoff     time.Duration
+	SMTPJitter         time.Duration
+	// SMTPRateLimitPerDomain and SMTPRateLimitBurst configure a token-bucket limiter
+	// keyed on the recipient's email domain, so a burst of sends to one domain
+	// can't trip that provider's per-domain rate cap. SMTPRateLimitPerDomain is in
+	// sends per second; zero disables rate limiting entirely.
+	SMTPRateLimitPerDomain float64
+	SMTPRateLimitBurst     float64
+
+	// SMTPDKIMKeyPath, if set, DKIM-signs every outgoing message with the PEM-encoded
+	// PKCS#8 private key (RSA or Ed25519) at that path, published under
+	// SMTPDKIMSelector._domainkey.SMTPDKIMDomain. Empty disables DKIM signing
+	// entirely - the other two fields are ignored.
+	SMTPDKIMKeyPath  string
+	SMTPDKIMSelector string
+	SMTPDKIMDomain   string
+	// SMTPUnsubscribeURLTemplate, if set, is rendered into the List-Unsubscribe/
+	// List-Unsubscribe-Post headers on account-activity notification mails (sign-in
+	// detected, password updated, email updated) - see
+	// actionsEmailSender.unsubscribeHeaders. "{email}" and "{type}" are replaced with
+	// the recipient's address and the notification type ("signin",
+	// "password_updated", or "email_updated"). Empty omits those headers entirely.
+	SMTPUnsubscribeURLTemplate string
+
+	// Mailgun settings (used when EmailBackend is "mailgun")
+	MailgunAPIKey string
+	MailgunDomain string
+	// MailgunBaseURL overrides the API host, e.g. for Mailgun's EU region
+	// (https://api.eu.mailgun.net/v3). Empty uses https://api.mailgun.net/v3.
+	MailgunBaseURL        string
+	MailgunRequestTimeout time.Duration
+
+	// AWS SES settings (used when EmailBackend is "ses"). There's no AWS SDK
+	// vendored in this module, so requests are signed by hand with SigV4 - see
+	// email_ses.go.
+	SESRegion          string
+	SESAccessKeyID     string
+	SESSecretAccessKey string
+	// SESSessionToken is only needed for temporary (STS) credentials.
+	SESSessionToken   string
+	SESRequestTimeout time.Duration
+
+	// WebhookURL is the URL each message is JSON-POSTed to when EmailBackend is
+	// "webhook". Empty logs the same JSON payload to stdout instead.
+	WebhookURL            string
+	WebhookRequestTimeout time.Duration
+
+	// PushURL configures the ntfy-compatible push notification backend (used when
+	// EmailBackend is "push", or by default when DisableSMTP is set and PushURL is
+	// non-empty): the ntfy topic URL to POST each notification to, with "{email}"
+	// replaced by the recipient's address - e.g. "https://ntfy.example.com/user-{email}".
+	PushURL string
+	// PushAuthUser/PushAuthPassword, if set, are sent as HTTP Basic auth credentials
+	// on each POST - ntfy supports protecting a topic this way.
+	PushAuthUser       string
+	PushAuthPassword   string
+	PushRequestTimeout time.Duration
+
+	// LoginThrottleMax is the number of free failed attempts allowed for a given
+	// (action, subject) pair before exponential backoff kicks in.
+	LoginThrottleMax int
+	// LoginThrottleWindow is the rolling window after which an idle (action, subject)
+	// pair's failure count resets, and the period the background sweeper uses to
+	// decide an entry is cold enough to evict.
+	LoginThrottleWindow time.Duration
+	// LoginThrottleCap bounds the exponential backoff delay.
+	LoginThrottleCap time.Duration
+
+	// PasswordResetCooldown is the minimum time a given email address must wait
+	// between two create_user_password_reset requests that are actually sent.
+	PasswordResetCooldown time.Duration
+	// PasswordResetDailyMax caps how many password reset emails a given email
+	// address may receive per UTC calendar day. 0 disables the quota.
+	PasswordResetDailyMax int
+	// PasswordResetTimeout is intended to control how long a generated temporary
+	// password stays valid. faroe v0.5.1 hardcodes this window internally
+	// (userPasswordResetExpiration, 20 minutes) with no exposed configuration
+	// hook, so this field is currently inert - it's wired through config/env for
+	// forward compatibility with a future faroe version that accepts it.
+	PasswordResetTimeout time.Duration
+
+	// AdminUsername/AdminPasswordHash gate the /reset endpoint (and any future admin
+	// routes) behind HTTP Basic auth - see requireAdminAuth in http_server.go.
+	// AdminPasswordHash is a bcrypt hash, never the plaintext password; generate one
+	// with `tiauth hash-password`. Both must be set for EnableReset to take effect.
+	AdminUsername     string
+	AdminPasswordHash string
+
+	// LogFormat selects the access log encoding: "text" (default - an Apache
+	// combined-log-ish line with request timing appended) or "json". AccessLogPath
+	// writes the access log to that file instead of stdout; the file is reopened on
+	// SIGHUP so a rename-in-place rotation doesn't need a restart - see access_log.go.
+	LogFormat     string
+	AccessLogPath string
+
+	// Argon2TimeCost and Argon2MemoryKiB (in KiB) are the argon2id params used to hash
+	// *new* user passwords (signup, password change, password reset); zero keeps the
+	// built-in defaults (3, 64*1024). Run `tiauth calibrate-argon2id` to measure what
+	// this host can afford at a target per-hash duration and get values to paste here
+	// - deliberately a one-shot, operator-run step rather than something Run (see
+	// app.go) recalculates on every startup: re-measuring at every restart would let
+	// ordinary timing jitter (CPU contention, a noisy neighbor) mint a different id on
+	// each boot and strand every hash created under the previous boot's id, since
+	// faroe matches stored hashes to an algorithm by an exact Id() string. Changing
+	// these only affects new hashes - see Run in app.go for how the previous fixed
+	// params (3, 64*1024), plus every generation listed in Argon2LegacyParams, are
+	// kept registered so hashes stored before the change keep verifying.
+	Argon2TimeCost  int
+	Argon2MemoryKiB int
+
+	// Argon2LegacyParams lists prior "timeCost:memoryKiB" generations (e.g.
+	// "5:8192,8:65536") still used to hash some stored passwords, most often because
+	// Argon2TimeCost/MemoryKiB has been recalibrated more than once. Every entry is
+	// kept registered for verification alongside the current params - omitting a
+	// generation a deployment actually used locks out every account hashed under it,
+	// so this should only grow, never drop an entry still in use.
+	Argon2LegacyParams []string
+
 	// Behavior flags
 	EnableReset       bool // Enable /reset endpoint to clear storage
 	EnableInteractive bool // Enable interactive shell mode
@@ -32,10 +271,46 @@ type Config struct {
 // DefaultConfig returns a Config with sensible defaults
 func DefaultConfig() Config {
 	return Config{
-		DBPath:            "./db.sqlite",
-		Port:              "3777",
-		PrivatePort:       8079,
-		SessionExpiration: 90 * 24 * time.Hour, // 90 days
+		DBPath:                "./db.sqlite",
+		MainDBPath:            "./db.sqlite",
+		Port:                  "3777",
+		TLSListenPort:         "443",
+		TLSAutoReloadInterval: time.Minute,
+		PrivatePort:           8079,
+		SessionExpiration:     90 * 24 * time.Hour, // 90 days
+		StorageBackend:        "sqlite3",
+		EtcdNamespace:         "tiauth",
+		EmailBackend:          "smtp",
+		LogFormat:             "text",
+
+		LoginThrottleMax:    4,
+		LoginThrottleWindow: 10 * time.Minute,
+		LoginThrottleCap:    5 * time.Minute,
+
+		PasswordResetCooldown: time.Hour,
+		PasswordResetDailyMax: 5,
+		PasswordResetTimeout:  24 * time.Hour,
+
+		SMTPKeepAlivePeriod: 15 * time.Second,
+		SMTPNoopInterval:    5 * time.Minute,
+
+		MailgunRequestTimeout: 15 * time.Second,
+		SESRequestTimeout:     15 * time.Second,
+		WebhookRequestTimeout: 15 * time.Second,
+		PushRequestTimeout:    15 * time.Second,
+	}
+}
+
+// TransportSecurity builds the BackendClient/UDSClient authentication settings from
+// the config's Transport* fields.
+func (cfg Config) TransportSecurity() TransportSecurity {
+	return TransportSecurity{
+		TLSCAFile:     cfg.TransportTLSCAFile,
+		TLSCertFile:   cfg.TransportTLSCertFile,
+		TLSKeyFile:    cfg.TransportTLSKeyFile,
+		TLSServerName: cfg.TransportTLSServerName,
+		HMACSecret:    cfg.TransportHMACSecret,
+		ReplayWindow:  cfg.TransportReplayWindow,
 	}
 }
 
@@ -110,9 +385,30 @@ func ConfigFromEnv(envFile string) (Config, error) {
 	if v := GetEnv(envMap, "FAROE_DB_PATH"); v != "" {
 		cfg.DBPath = v
 	}
+	cfg.MainDBPath = GetEnvDefault(envMap, "FAROE_MAIN_DB_PATH", cfg.DBPath)
 	if v := GetEnv(envMap, "FAROE_PORT"); v != "" {
 		cfg.Port = v
 	}
+
+	cfg.TLSCertFile = GetEnv(envMap, "FAROE_TLS_CERT_FILE")
+	cfg.TLSKeyFile = GetEnv(envMap, "FAROE_TLS_KEY_FILE")
+	cfg.TLSListenPort = GetEnvDefault(envMap, "FAROE_TLS_LISTEN_PORT", "443")
+	if v := GetEnv(envMap, "FAROE_TLS_AUTO_RELOAD_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.TLSAutoReloadInterval = d
+		}
+	}
+
+	cfg.StorageBackend = GetEnvDefault(envMap, "FAROE_STORAGE_BACKEND", "sqlite3")
+	cfg.PostgresDSN = GetEnv(envMap, "FAROE_POSTGRES_DSN")
+	if v := GetEnv(envMap, "FAROE_ETCD_ENDPOINTS"); v != "" {
+		cfg.EtcdEndpoints = strings.Split(v, ",")
+	}
+	cfg.EtcdNamespace = GetEnvDefault(envMap, "FAROE_ETCD_NAMESPACE", "tiauth")
+	cfg.EtcdCAFile = GetEnv(envMap, "FAROE_ETCD_CA_FILE")
+	cfg.EtcdCertFile = GetEnv(envMap, "FAROE_ETCD_CERT_FILE")
+	cfg.EtcdKeyFile = GetEnv(envMap, "FAROE_ETCD_KEY_FILE")
+	cfg.EtcdServerName = GetEnv(envMap, "FAROE_ETCD_SERVER_NAME")
 	if v := GetEnv(envMap, "FAROE_PRIVATE_PORT"); v != "" {
 		if port, err := strconv.Atoi(v); err == nil {
 			cfg.PrivatePort = port
@@ -120,18 +416,222 @@ func ConfigFromEnv(envFile string) (Config, error) {
 	}
 	cfg.CORSAllowOrigin = GetEnv(envMap, "FAROE_CORS_ALLOW_ORIGIN")
 
+	cfg.AdminUsername = GetEnv(envMap, "FAROE_ADMIN_USERNAME")
+	cfg.AdminPasswordHash = GetEnv(envMap, "FAROE_ADMIN_PASSWORD_HASH")
+
+	cfg.LogFormat = GetEnvDefault(envMap, "FAROE_LOG_FORMAT", "text")
+	cfg.AccessLogPath = GetEnv(envMap, "FAROE_ACCESS_LOG_PATH")
+
+	if v := GetEnv(envMap, "FAROE_ARGON2_TIME_COST"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.Argon2TimeCost = n
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_ARGON2_MEMORY_KIB"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.Argon2MemoryKiB = n
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_ARGON2_LEGACY_PARAMS"); v != "" {
+		for _, param := range strings.Split(v, ",") {
+			if param = strings.TrimSpace(param); param != "" {
+				cfg.Argon2LegacyParams = append(cfg.Argon2LegacyParams, param)
+			}
+		}
+	}
+
 	if v := GetEnv(envMap, "FAROE_SESSION_EXPIRATION"); v != "" {
 		if d, err := time.ParseDuration(v); err == nil {
 			cfg.SessionExpiration = d
 		}
 	}
 
+	cfg.UserActionInvocationURL = GetEnv(envMap, "FAROE_USER_ACTION_INVOCATION_URL")
+	cfg.PrivateRouteKeyFile = GetEnv(envMap, "FAROE_PRIVATE_ROUTE_KEY_FILE")
+	cfg.TokenSocketPath = GetEnv(envMap, "FAROE_TOKEN_SOCKET_PATH")
+
+	cfg.TransportTLSCAFile = GetEnv(envMap, "FAROE_TRANSPORT_TLS_CA_FILE")
+	cfg.TransportTLSCertFile = GetEnv(envMap, "FAROE_TRANSPORT_TLS_CERT_FILE")
+	cfg.TransportTLSKeyFile = GetEnv(envMap, "FAROE_TRANSPORT_TLS_KEY_FILE")
+	cfg.TransportTLSServerName = GetEnv(envMap, "FAROE_TRANSPORT_TLS_SERVER_NAME")
+	cfg.TransportHMACSecret = GetEnv(envMap, "FAROE_TRANSPORT_HMAC_SECRET")
+	if v := GetEnv(envMap, "FAROE_TRANSPORT_REPLAY_WINDOW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.TransportReplayWindow = d
+		}
+	}
+
+	cfg.DisableSMTP = GetEnvDefault(envMap, "FAROE_DISABLE_SMTP", "") == "true"
+	cfg.PushURL = GetEnv(envMap, "FAROE_PUSH_URL")
+	cfg.PushAuthUser = GetEnv(envMap, "FAROE_PUSH_AUTH_USER")
+	cfg.PushAuthPassword = GetEnv(envMap, "FAROE_PUSH_AUTH_PASSWORD")
+	if v := GetEnv(envMap, "FAROE_PUSH_REQUEST_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.PushRequestTimeout = d
+		}
+	}
+
+	// FAROE_EMAIL_BACKEND takes precedence; FAROE_DISABLE_SMTP is kept as a legacy
+	// alias for "stdout" (or "push", if FAROE_PUSH_URL is also set) so existing
+	// deployments don't need to change their env file.
+	defaultEmailBackend := "smtp"
+	if cfg.DisableSMTP {
+		defaultEmailBackend = "stdout"
+		if cfg.PushURL != "" {
+			defaultEmailBackend = "push"
+		}
+	}
+	cfg.EmailBackend = GetEnvDefault(envMap, "FAROE_EMAIL_BACKEND", defaultEmailBackend)
+	// FAROE_SMTP_TLS_MODE takes precedence; FAROE_SMTP_INSECURE is kept as a legacy
+	// alias for "none" so existing deployments don't need to change their env file.
+	defaultTLSMode := ""
+	if GetEnvDefault(envMap, "FAROE_SMTP_INSECURE", "") == "true" {
+		defaultTLSMode = "none"
+	}
+	cfg.SMTPTLSMode = GetEnvDefault(envMap, "FAROE_SMTP_TLS_MODE", defaultTLSMode)
+	cfg.NoKeepAlive = GetEnvDefault(envMap, "FAROE_SMTP_NO_KEEP_ALIVE", "") == "true"
+	cfg.SMTPSenderName = GetEnv(envMap, "FAROE_SMTP_SENDER_NAME")
+	cfg.SMTPSenderEmail = GetEnv(envMap, "FAROE_SMTP_SENDER_EMAIL")
+	cfg.SMTPServerHost = GetEnv(envMap, "FAROE_SMTP_SERVER_HOST")
+	cfg.SMTPServerPort = GetEnv(envMap, "FAROE_SMTP_SERVER_PORT")
+	cfg.SMTPDomain = GetEnv(envMap, "FAROE_SMTP_DOMAIN")
+	cfg.EmailTemplatesPath = GetEnv(envMap, "FAROE_EMAIL_TEMPLATES_PATH")
+
+	cfg.SMTPAuthMethod = GetEnv(envMap, "FAROE_SMTP_AUTH_METHOD")
+	cfg.SMTPUsername = GetEnv(envMap, "FAROE_SMTP_USERNAME")
+	cfg.SMTPPassword = GetEnv(envMap, "FAROE_SMTP_PASSWORD")
+	cfg.SMTPAllowInsecureAuth = GetEnvDefault(envMap, "FAROE_SMTP_ALLOW_INSECURE_AUTH", "") == "true"
+	cfg.SMTPOAuthUsername = GetEnv(envMap, "FAROE_SMTP_OAUTH_USERNAME")
+	cfg.SMTPOAuthTokenURL = GetEnv(envMap, "FAROE_SMTP_OAUTH_TOKEN_URL")
+	cfg.SMTPOAuthClientID = GetEnv(envMap, "FAROE_SMTP_OAUTH_CLIENT_ID")
+	cfg.SMTPOAuthClientSecret = GetEnv(envMap, "FAROE_SMTP_OAUTH_CLIENT_SECRET")
+	cfg.SMTPOAuthRefreshToken = GetEnv(envMap, "FAROE_SMTP_OAUTH_REFRESH_TOKEN")
+
+	if v := GetEnv(envMap, "FAROE_SMTP_CONNECTION_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.SMTPConnectionTimeout = d
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_SMTP_KEEPALIVE_PERIOD"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.SMTPKeepAlivePeriod = d
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_SMTP_KEEPALIVE_PROBES"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.SMTPKeepAliveProbes = n
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_SMTP_KEEPALIVE_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.SMTPKeepAliveInterval = d
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_SMTP_NOOP_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.SMTPNoopInterval = d
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_SMTP_MAX_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.SMTPMaxAttempts = n
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_SMTP_INITIAL_BACKOFF"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.SMTPInitialBackoff = d
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_SMTP_MAX_BACKOFF"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.SMTPMaxBackoff = d
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_SMTP_JITTER"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.SMTPJitter = d
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_SMTP_RATE_LIMIT_PER_DOMAIN"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			cfg.SMTPRateLimitPerDomain = f
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_SMTP_RATE_LIMIT_BURST"); v != "" {
+		if f, err := strconv.ParseFloat(v, 64); err == nil {
+			cfg.SMTPRateLimitBurst = f
+		}
+	}
+	cfg.SMTPDKIMKeyPath = GetEnv(envMap, "FAROE_SMTP_DKIM_KEY_PATH")
+	cfg.SMTPDKIMSelector = GetEnv(envMap, "FAROE_SMTP_DKIM_SELECTOR")
+	cfg.SMTPDKIMDomain = GetEnv(envMap, "FAROE_SMTP_DKIM_DOMAIN")
+	cfg.SMTPUnsubscribeURLTemplate = GetEnv(envMap, "FAROE_SMTP_UNSUBSCRIBE_URL_TEMPLATE")
+
+	cfg.MailgunAPIKey = GetEnv(envMap, "FAROE_MAILGUN_API_KEY")
+	cfg.MailgunDomain = GetEnv(envMap, "FAROE_MAILGUN_DOMAIN")
+	cfg.MailgunBaseURL = GetEnv(envMap, "FAROE_MAILGUN_BASE_URL")
+	if v := GetEnv(envMap, "FAROE_MAILGUN_REQUEST_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.MailgunRequestTimeout = d
+		}
+	}
+
+	cfg.SESRegion = GetEnv(envMap, "FAROE_SES_REGION")
+	cfg.SESAccessKeyID = GetEnv(envMap, "FAROE_SES_ACCESS_KEY_ID")
+	cfg.SESSecretAccessKey = GetEnv(envMap, "FAROE_SES_SECRET_ACCESS_KEY")
+	cfg.SESSessionToken = GetEnv(envMap, "FAROE_SES_SESSION_TOKEN")
+	if v := GetEnv(envMap, "FAROE_SES_REQUEST_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.SESRequestTimeout = d
+		}
+	}
+
+	cfg.WebhookURL = GetEnv(envMap, "FAROE_WEBHOOK_URL")
+	if v := GetEnv(envMap, "FAROE_WEBHOOK_REQUEST_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.WebhookRequestTimeout = d
+		}
+	}
+
+	if v := GetEnv(envMap, "FAROE_LOGIN_THROTTLE_MAX"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.LoginThrottleMax = n
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_LOGIN_THROTTLE_WINDOW"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.LoginThrottleWindow = d
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_LOGIN_THROTTLE_CAP"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.LoginThrottleCap = d
+		}
+	}
+
+	if v := GetEnv(envMap, "FAROE_PASSWORD_RESET_COOLDOWN"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.PasswordResetCooldown = d
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_PASSWORD_RESET_DAILY_MAX"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.PasswordResetDailyMax = n
+		}
+	}
+	if v := GetEnv(envMap, "FAROE_PASSWORD_RESET_TIMEOUT"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			cfg.PasswordResetTimeout = d
+		}
+	}
+
 	return cfg, nil
 }
 
 // Flags holds the parsed command line flags
 type Flags struct {
 	EnvFile     string
+	Init        bool
 	Interactive bool
 	EnableReset bool
 	PrivatePort int
@@ -146,6 +646,7 @@ func RegisterFlags(fs *flag.FlagSet) *Flags {
 
 	f := &Flags{}
 	fs.StringVar(&f.EnvFile, "env-file", ".env", "Path to environment file")
+	fs.BoolVar(&f.Init, "init", false, "If env-file does not exist, write the embedded default config to it before loading")
 	fs.BoolVar(&f.Interactive, "interactive", false, "Run in interactive mode with stdin commands")
 	fs.BoolVar(&f.EnableReset, "enable-reset", false, "Enable request to /reset to clear storage")
 	fs.IntVar(&f.PrivatePort, "private-port", 0, "Port for Python backend communication (binds to 127.0.0.2)")
@@ -156,6 +657,15 @@ func RegisterFlags(fs *flag.FlagSet) *Flags {
 // ConfigFromFlags loads config from env file and applies flag overrides.
 // Call this after flag.Parse().
 func ConfigFromFlags(f *Flags) (Config, error) {
+	if f.Init {
+		if _, err := os.Stat(f.EnvFile); os.IsNotExist(err) {
+			if err := os.WriteFile(f.EnvFile, []byte(DefaultEnvTemplate()), 0o600); err != nil {
+				return Config{}, fmt.Errorf("failed to write default env file to %s: %v", f.EnvFile, err)
+			}
+			log.Printf("Wrote default env file to %s", f.EnvFile)
+		}
+	}
+
 	cfg, err := ConfigFromEnv(f.EnvFile)
 	if err != nil {
 		return cfg, err
@@ -180,3 +690,13 @@ func ParseFlagsAndConfig() (Config, error) {
 	flag.Parse()
 	return ConfigFromFlags(f)
 }
+
+// HashPassword bcrypt-hashes password for use as FAROE_ADMIN_PASSWORD_HASH. Exposed
+// for the `tiauth hash-password` CLI helper; see cmd/main.go.
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %v", err)
+	}
+	return string(hash), nil
+}
@@ -0,0 +1,73 @@
+package tiauth
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// deadlineTimer implements the deadline-based cancellation pattern used throughout
+// net packages (see e.g. Go's internal/poll deadlineTimer): a timer is armed for the
+// deadline and cancelCh is closed when it fires. Callers re-arm the same deadlineTimer
+// on every SetReadDeadline/SetWriteDeadline call; the previous cancelCh is abandoned
+// and the previous timer stopped, so only the most recently set deadline is ever live.
+type deadlineTimer struct {
+	mu       sync.Mutex
+	timer    *time.Timer
+	cancelCh chan struct{}
+}
+
+// newDeadlineTimer returns a deadlineTimer with no deadline armed.
+func newDeadlineTimer() *deadlineTimer {
+	return &deadlineTimer{cancelCh: make(chan struct{})}
+}
+
+// set arms the timer for t and returns the cancelCh that will be closed when it fires.
+// A zero t disarms the timer; the returned channel is then never closed by the timer
+// itself (it is still closed if a later deadline fires or disarm is called again only
+// replaces it, so callers should always use the channel returned by the most recent call).
+func (d *deadlineTimer) set(t time.Time) <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.timer != nil {
+		d.timer.Stop()
+	}
+
+	cancelCh := make(chan struct{})
+	d.cancelCh = cancelCh
+
+	if t.IsZero() {
+		d.timer = nil
+		return cancelCh
+	}
+
+	d.timer = time.AfterFunc(time.Until(t), func() {
+		close(cancelCh)
+	})
+
+	return cancelCh
+}
+
+// get returns the current cancelCh. Callers (e.g. requestContext) must use this
+// instead of reading d.cancelCh directly, since set replaces it under d.mu on every
+// SetReadDeadline/SetWriteDeadline call.
+func (d *deadlineTimer) get() <-chan struct{} {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.cancelCh
+}
+
+// withCancelCh returns a context derived from parent that is canceled when either
+// parent is done or cancelCh is closed.
+func withCancelCh(parent context.Context, cancelCh <-chan struct{}) (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(parent)
+	go func() {
+		select {
+		case <-cancelCh:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
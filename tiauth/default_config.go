@@ -0,0 +1,15 @@
+package tiauth
+
+import _ "embed"
+
+// defaultEnvTemplate is the starter .env file shipped inside the binary so operators
+// don't need to copy example files alongside it. See [DefaultEnvTemplate] and the
+// `defaultconfig` cmd subcommand.
+//
+//go:embed default.env
+var defaultEnvTemplate string
+
+// DefaultEnvTemplate returns the embedded starter .env file content.
+func DefaultEnvTemplate() string {
+	return defaultEnvTemplate
+}
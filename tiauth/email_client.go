@@ -0,0 +1,64 @@
+package tiauth
+
+import (
+	"context"
+	"time"
+)
+
+// Message is one rendered email, built by actionsEmailSender's per-action methods
+// (smtp_sender.go) and handed to whichever EmailClient the configured backend
+// constructs. Text is always populated as the universal fallback body; HTML and
+// Markdown are optional richer renderings a client may prefer instead - a client that
+// doesn't understand one just ignores it.
+type Message struct {
+	Subject  string
+	Text     string
+	HTML     string
+	Markdown string
+	// ExtraHeaders are raw "Name: value" header lines appended verbatim after the
+	// usual From/To/Subject/Date/Message-ID/MIME headers - e.g. List-Unsubscribe on
+	// a notification mail (see actionsEmailSender.unsubscribeHeaders). A client that
+	// doesn't build raw RFC 5322 headers itself (Mailgun, SES, webhook all send
+	// through a structured API) may ignore this.
+	ExtraHeaders []string
+}
+
+// EmailClient delivers a [Message] through one outbound email provider. fromName may
+// be empty, in which case fromAddr alone is used as the sender identity. Concrete
+// implementations: smtpEmailClient (smtp_sender.go), mailgunEmailClient
+// (email_mailgun.go), sesEmailClient (email_ses.go), webhookEmailClient
+// (email_webhook.go). Config.EmailBackend selects which one app.go constructs.
+type EmailClient interface {
+	Send(fromName, fromAddr string, msg *Message, to ...string) error
+}
+
+// emailClientLifecycle is implemented by EmailClients that hold a persistent
+// connection needing an explicit start (e.g. dialing and authenticating once up
+// front, then keeping the connection alive) and stop (releasing it on shutdown).
+// Stateless per-call HTTP clients (Mailgun, SES, webhook) don't need it;
+// actionsEmailSender type-asserts for it and no-ops when a client doesn't implement
+// it.
+type emailClientLifecycle interface {
+	Start(interval time.Duration) error
+	Close() error
+}
+
+// emailClientContextSender is implemented by EmailClients whose Send can respect
+// context cancellation mid-delivery - currently only smtpEmailClient, where an
+// in-flight retry's backoff sleep can be a multi-second wait. actionsEmailSender
+// type-asserts for it and falls back to the plain Send (which runs to completion
+// regardless of ctx) when a client doesn't implement it.
+type emailClientContextSender interface {
+	SendCtx(ctx context.Context, fromName, fromAddr string, msg *Message, to ...string) error
+}
+
+// emailClientHealthChecker is implemented by EmailClients that can report their
+// current connection health on demand - currently only smtpEmailClient. httpServer
+// type-asserts for it to back /healthz, and treats a client that doesn't implement
+// it (Mailgun, SES, webhook - all stateless per-call HTTP clients with nothing to
+// probe) as always healthy.
+type emailClientHealthChecker interface {
+	Healthy() bool
+	LastError() error
+	ProbeNow() error
+}
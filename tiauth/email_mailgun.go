@@ -0,0 +1,96 @@
+package tiauth
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// mailgunConfig configures the Mailgun HTTP API client.
+type mailgunConfig struct {
+	// apiKey is sent as the password half of HTTP Basic auth, username "api" - the
+	// same convention used by Mailgun's own client libraries and by the dex/jfa-go
+	// integrations this was modeled on.
+	apiKey string
+	// domain is the sending domain registered with Mailgun (or its sandbox domain
+	// for testing), used to build the API base URL.
+	domain string
+	// baseURL overrides the API host, for Mailgun's EU region
+	// (https://api.eu.mailgun.net/v3) or a test server. Empty uses
+	// https://api.mailgun.net/v3.
+	baseURL string
+	// requestTimeout bounds each API call. Zero means no timeout.
+	requestTimeout time.Duration
+}
+
+// mailgunEmailClient sends mail through the Mailgun HTTP API
+// (https://documentation.mailgun.com/en/latest/api-sending.html#sending). It is
+// stateless between calls - no [emailClientLifecycle] needed.
+type mailgunEmailClient struct {
+	config     mailgunConfig
+	httpClient *http.Client
+}
+
+func newMailgunEmailClient(config mailgunConfig) *mailgunEmailClient {
+	return &mailgunEmailClient{
+		config:     config,
+		httpClient: &http.Client{Timeout: config.requestTimeout},
+	}
+}
+
+func (c *mailgunEmailClient) apiURL() string {
+	base := c.config.baseURL
+	if base == "" {
+		base = "https://api.mailgun.net/v3"
+	}
+	return strings.TrimSuffix(base, "/") + "/" + c.config.domain + "/messages"
+}
+
+// Send implements [EmailClient] by POSTing one multipart/form-data request per call
+// with all of to as separate "to" fields, matching how Mailgun accepts multiple
+// recipients in a single send.
+func (c *mailgunEmailClient) Send(fromName, fromAddr string, msg *Message, to ...string) error {
+	from := fromAddr
+	if fromName != "" {
+		from = fmt.Sprintf("%s <%s>", fromName, fromAddr)
+	}
+
+	form := url.Values{}
+	form.Set("from", from)
+	for _, receiverEmail := range to {
+		form.Add("to", receiverEmail)
+	}
+	form.Set("subject", msg.Subject)
+	if msg.Text != "" {
+		form.Set("text", msg.Text)
+	}
+	if msg.HTML != "" {
+		form.Set("html", msg.HTML)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.apiURL(), strings.NewReader(form.Encode()))
+	if err != nil {
+		return fmt.Errorf("failed to build Mailgun request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth("api", c.config.apiKey)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call Mailgun API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read Mailgun response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Mailgun API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	return nil
+}
@@ -0,0 +1,251 @@
+package tiauth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// sesConfig configures the AWS SES v2 HTTP API client. There's no AWS SDK vendored
+// in this module (no network access to fetch one), so requests are signed by hand
+// with SigV4 (crypto/hmac + crypto/sha256) instead of via aws-sdk-go.
+type sesConfig struct {
+	region          string
+	accessKeyID     string
+	secretAccessKey string
+	// sessionToken is only needed when accessKeyID/secretAccessKey are temporary
+	// (STS) credentials; empty for long-lived IAM user keys.
+	sessionToken string
+	// requestTimeout bounds each API call. Zero means no timeout.
+	requestTimeout time.Duration
+}
+
+// sesEmailClient sends mail through the SES v2 SendEmail HTTP API
+// (https://docs.aws.amazon.com/ses/latest/APIReference-V2/API_SendEmail.html),
+// authenticated with a hand-rolled AWS Signature Version 4. It is stateless between
+// calls - no [emailClientLifecycle] needed.
+type sesEmailClient struct {
+	config     sesConfig
+	httpClient *http.Client
+}
+
+func newSESEmailClient(config sesConfig) *sesEmailClient {
+	return &sesEmailClient{
+		config:     config,
+		httpClient: &http.Client{Timeout: config.requestTimeout},
+	}
+}
+
+func (c *sesEmailClient) endpoint() string {
+	return fmt.Sprintf("https://email.%s.amazonaws.com/v2/email/outbound-emails", c.config.region)
+}
+
+// sesSendEmailRequest mirrors the subset of SES v2's SendEmailRequest this client
+// populates: a simple (non-templated, non-raw) message to one or more destinations.
+type sesSendEmailRequest struct {
+	FromEmailAddress string          `json:"FromEmailAddress"`
+	Destination      sesDestination  `json:"Destination"`
+	Content          sesEmailContent `json:"Content"`
+}
+
+type sesDestination struct {
+	ToAddresses []string `json:"ToAddresses"`
+}
+
+type sesEmailContent struct {
+	Simple sesSimpleMessage `json:"Simple"`
+}
+
+type sesSimpleMessage struct {
+	Subject sesContentBody `json:"Subject"`
+	Body    sesMessageBody `json:"Body"`
+}
+
+type sesMessageBody struct {
+	Text *sesContentBody `json:"Text,omitempty"`
+	HTML *sesContentBody `json:"Html,omitempty"`
+}
+
+type sesContentBody struct {
+	Data string `json:"Data"`
+}
+
+// Send implements [EmailClient]. SES v2's SendEmail takes a single FromEmailAddress
+// with an optional display name via RFC 5322 syntax ("Name <addr>"), same as SMTP.
+func (c *sesEmailClient) Send(fromName, fromAddr string, msg *Message, to ...string) error {
+	from := fromAddr
+	if fromName != "" {
+		from = fmt.Sprintf("%s <%s>", fromName, fromAddr)
+	}
+
+	body := sesMessageBody{}
+	if msg.Text != "" {
+		body.Text = &sesContentBody{Data: msg.Text}
+	}
+	if msg.HTML != "" {
+		body.HTML = &sesContentBody{Data: msg.HTML}
+	}
+
+	reqBody, err := json.Marshal(sesSendEmailRequest{
+		FromEmailAddress: from,
+		Destination:      sesDestination{ToAddresses: to},
+		Content: sesEmailContent{Simple: sesSimpleMessage{
+			Subject: sesContentBody{Data: msg.Subject},
+			Body:    body,
+		}},
+	})
+	if err != nil {
+		return fmt.Errorf("failed to build SES request body: %v", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.endpoint(), bytes.NewReader(reqBody))
+	if err != nil {
+		return fmt.Errorf("failed to build SES request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	if err := signSigV4(req, reqBody, c.config, time.Now().UTC()); err != nil {
+		return fmt.Errorf("failed to sign SES request: %v", err)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call SES API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read SES response: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("SES API returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
+
+// signSigV4 signs req in place with AWS Signature Version 4 for the "ses" service,
+// following the canonical-request/string-to-sign/signing-key recipe from
+// https://docs.aws.amazon.com/general/latest/gr/sigv4-signed-request.html. Hand
+// rolled because aws-sdk-go isn't available in this module's dependency cache.
+func signSigV4(req *http.Request, body []byte, cfg sesConfig, now time.Time) error {
+	amzDate := now.Format("20060102T150405Z")
+	dateStamp := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	if cfg.sessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", cfg.sessionToken)
+	}
+
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	signedHeaders, canonicalHeaders := canonicalHeadersFor(req)
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		canonicalURI(req.URL.Path),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	credentialScope := strings.Join([]string{dateStamp, cfg.region, "ses", "aws4_request"}, "/")
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		credentialScope,
+		sha256Hex([]byte(canonicalRequest)),
+	}, "\n")
+
+	signingKey := sigV4SigningKey(cfg.secretAccessKey, dateStamp, cfg.region, "ses")
+	signature := hex.EncodeToString(hmacSHA256(signingKey, stringToSign))
+
+	authHeader := fmt.Sprintf(
+		"AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		cfg.accessKeyID, credentialScope, signedHeaders, signature,
+	)
+	req.Header.Set("Authorization", authHeader)
+
+	return nil
+}
+
+// canonicalURI returns path unchanged: every character SES's API paths use
+// (letters, digits, "/", "-") is already URI-safe, so no percent-encoding pass is
+// needed here.
+func canonicalURI(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// canonicalHeadersFor returns SigV4's canonical-headers block and semicolon-joined
+// signed-headers list for req, over the fixed set of headers this client always
+// sets (host, the x-amz-* headers, and content-type).
+func canonicalHeadersFor(req *http.Request) (signedHeaders string, canonicalHeaders string) {
+	headerNames := []string{"content-type", "host", "x-amz-content-sha256", "x-amz-date"}
+	if req.Header.Get("X-Amz-Security-Token") != "" {
+		headerNames = append(headerNames, "x-amz-security-token")
+	}
+	sort.Strings(headerNames)
+
+	host := req.Host
+	if host == "" {
+		host = req.URL.Host
+	}
+
+	var canon strings.Builder
+	for _, name := range headerNames {
+		canon.WriteString(name)
+		canon.WriteString(":")
+		if name == "host" {
+			canon.WriteString(host)
+		} else {
+			canon.WriteString(strings.TrimSpace(req.Header.Get(httpCanonicalHeaderName(name))))
+		}
+		canon.WriteString("\n")
+	}
+
+	return strings.Join(headerNames, ";"), canon.String()
+}
+
+// httpCanonicalHeaderName looks up a lower-cased SigV4 header name in req.Header,
+// which net/http stores under its canonical MIME form (e.g. "X-Amz-Date").
+func httpCanonicalHeaderName(lower string) string {
+	parts := strings.Split(lower, "-")
+	for i, p := range parts {
+		if len(p) > 0 {
+			parts[i] = strings.ToUpper(p[:1]) + p[1:]
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+func sigV4SigningKey(secretAccessKey, dateStamp, region, service string) []byte {
+	kDate := hmacSHA256([]byte("AWS4"+secretAccessKey), dateStamp)
+	kRegion := hmacSHA256(kDate, region)
+	kService := hmacSHA256(kRegion, service)
+	return hmacSHA256(kService, "aws4_request")
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,15 @@
+package tiauth
+
+import "embed"
+
+// defaultEmailTemplatesFS holds the built-in templates used in prod mode when no
+// FAROE_EMAIL_TEMPLATES_PATH directory is configured.
+//
+//go:embed email_templates/*.txt
+var defaultEmailTemplatesFS embed.FS
+
+// newDefaultTemplateRenderer returns the [TemplateRenderer] backed by the templates
+// embedded in the binary.
+func newDefaultTemplateRenderer() (TemplateRenderer, error) {
+	return NewEmbeddedTemplateRenderer(defaultEmailTemplatesFS, "email_templates")
+}
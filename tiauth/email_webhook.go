@@ -0,0 +1,95 @@
+package tiauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"time"
+)
+
+// webhookConfig configures the generic webhook/JSON-POST client: a stand-in provider
+// for local dev and test harnesses that doesn't talk to a real email service at all.
+type webhookConfig struct {
+	// url is where each message is POSTed as JSON. Empty means "no URL configured" -
+	// webhookEmailClient then just logs the payload to stdout instead, which is the
+	// simplest possible way to see what tiauth would have sent without a provider
+	// account of any kind.
+	url string
+	// requestTimeout bounds each POST when url is set. Zero means no timeout.
+	requestTimeout time.Duration
+}
+
+// webhookPayload is the JSON body POSTed to webhookConfig.url (and what's logged to
+// stdout when no URL is configured).
+type webhookPayload struct {
+	FromName string   `json:"from_name,omitempty"`
+	FromAddr string   `json:"from_addr"`
+	To       []string `json:"to"`
+	Subject  string   `json:"subject"`
+	Text     string   `json:"text,omitempty"`
+	HTML     string   `json:"html,omitempty"`
+	Markdown string   `json:"markdown,omitempty"`
+}
+
+// webhookEmailClient implements [EmailClient] by either POSTing a JSON payload to a
+// configured URL (for a test harness to receive) or, when no URL is configured,
+// dumping the same payload to stdout (for local dev without any provider at all). It
+// is stateless between calls - no [emailClientLifecycle] needed.
+type webhookEmailClient struct {
+	config     webhookConfig
+	httpClient *http.Client
+}
+
+func newWebhookEmailClient(config webhookConfig) *webhookEmailClient {
+	return &webhookEmailClient{
+		config:     config,
+		httpClient: &http.Client{Timeout: config.requestTimeout},
+	}
+}
+
+func (c *webhookEmailClient) Send(fromName, fromAddr string, msg *Message, to ...string) error {
+	payload := webhookPayload{
+		FromName: fromName,
+		FromAddr: fromAddr,
+		To:       to,
+		Subject:  msg.Subject,
+		Text:     msg.Text,
+		HTML:     msg.HTML,
+		Markdown: msg.Markdown,
+	}
+
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to build webhook payload: %v", err)
+	}
+
+	if c.config.url == "" {
+		log.Printf("[email:webhook] %s", string(body))
+		return nil
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.config.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to build webhook request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to POST webhook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read webhook response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook endpoint returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+
+	return nil
+}
@@ -1,36 +1,193 @@
 package tiauth
 
 import (
+	"crypto/rand"
+	"crypto/subtle"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
 	"io"
 	"log"
+	"net"
 	"net/http"
+	"strconv"
 	"time"
 
 	"github.com/faroedev/faroe"
+	"github.com/tiptenbrink/tiauth-faroe/tiauth/throttle"
+	"golang.org/x/crypto/bcrypt"
 )
 
 type httpServer struct {
-	server          *faroe.ServerStruct
-	storage         *storageStruct
-	enableReset     bool
-	corsAllowOrigin string
-	errChan         chan error
+	server                 *faroe.ServerStruct
+	storage                Storage
+	enableReset            bool
+	adminUsername          string
+	adminPasswordHash      string
+	corsAllowOrigin        string
+	throttler              *throttle.Throttler      // nil disables throttling
+	passwordResetThrottle  *passwordResetThrottle   // nil disables the reset cooldown/quota
+	emailHealth            emailClientHealthChecker // nil if the email backend doesn't support health checks
+	accessLog              *accessLogger
+	actionLoggerRequestIDs *actionLoggerRequestIDs
+	errChan                chan error
 }
 
+// throttledSubjectFields lists, in priority order, the action-invocation argument
+// fields that identify who is attempting an action we want to throttle. The
+// invocation endpoint only ever carries opaque flow tokens (never a raw email or
+// user id), so the token itself - scoped to the calling IP - stands in for the
+// "subject" the action is being attempted against.
+var throttledSubjectFields = []string{
+	"signin_token",
+	"signup_token",
+	"user_password_reset_token",
+	"user_email_address_update_token",
+	"user_password_update_token",
+	"user_deletion_token",
+	"session_token",
+}
+
+// throttledActions are the action invocation endpoint actions that attempt a
+// password or a verification code against an existing flow, and so can be used to
+// brute-force a guess or to trigger repeated Argon2id hashing.
+var throttledActions = map[string]bool{
+	"verify_signin_user_password":                                      true,
+	"verify_signup_email_address_verification_code":                    true,
+	"verify_user_password_reset_temporary_password":                    true,
+	"verify_user_email_address_update_email_address_verification_code": true,
+	"verify_user_email_address_update_user_password":                   true,
+	"verify_user_password_update_user_password":                        true,
+	"verify_user_deletion_user_password":                               true,
+}
+
+// throttleKey extracts the (action, subject) key a request to the action invocation
+// endpoint should be throttled under, if any. ok is false for actions that aren't
+// throttled or whose body couldn't be parsed.
+func throttleKey(bodyBytes []byte, remoteIP string) (action string, subject string, ok bool) {
+	var body struct {
+		Action    string         `json:"action"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	if err := json.Unmarshal(bodyBytes, &body); err != nil {
+		return "", "", false
+	}
+	if !throttledActions[body.Action] {
+		return "", "", false
+	}
+
+	token := ""
+	for _, field := range throttledSubjectFields {
+		if v, present := body.Arguments[field].(string); present && v != "" {
+			token = v
+			break
+		}
+	}
+
+	return body.Action, token + "@" + remoteIP, true
+}
+
+// passwordResetEmail extracts the target email address from a create_user_password_reset
+// action invocation endpoint request, if bodyBytes is one.
+func passwordResetEmail(bodyBytes []byte) (email string, ok bool) {
+	var body struct {
+		Action    string         `json:"action"`
+		Arguments map[string]any `json:"arguments"`
+	}
+	if err := json.Unmarshal(bodyBytes, &body); err != nil {
+		return "", false
+	}
+	if body.Action != createUserPasswordResetAction {
+		return "", false
+	}
+	email, ok = body.Arguments["user_email_address"].(string)
+	return email, ok && email != ""
+}
+
+// clientIP returns the request's remote address with any port stripped.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// createUserPasswordResetAction is the action invocation endpoint action that sends
+// a user a temporary password, gated by passwordResetThrottle before it reaches faroe.
+const createUserPasswordResetAction = "create_user_password_reset"
+
+// randomActionInvocationID generates an opaque id for a synthetic action invocation
+// endpoint response, in the same style as faroe's own generated ids.
+func randomActionInvocationID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// actionResultOk reports whether a successful action invocation endpoint response
+// (resultJSON) indicates the action itself succeeded, via its top-level "ok" field.
+func actionResultOk(resultJSON string) bool {
+	var result struct {
+		Ok bool `json:"ok"`
+	}
+	if err := json.Unmarshal([]byte(resultJSON), &result); err != nil {
+		return false
+	}
+	return result.Ok
+}
+
+// listen starts the plaintext HTTP listener on port, reporting a listen error on
+// server.errChan. Always call this before listenTLS - it's the one that creates
+// errChan, sized to hold an error from both listeners.
 func (server *httpServer) listen(port string) {
-	errChan := make(chan error, 1)
+	server.errChan = make(chan error, 2)
 
 	go func() {
-		defer close(errChan)
 		log.Printf("Listening on port %s...", port)
-		err := http.ListenAndServe(fmt.Sprintf(":%s", port), http.HandlerFunc(server.handle))
+		err := http.ListenAndServe(fmt.Sprintf(":%s", port), http.HandlerFunc(server.loggingHandler))
 		if err != nil {
-			errChan <- err
+			server.errChan <- err
 		}
 	}()
+}
 
-	server.errChan = errChan
+// listenTLS starts an additional HTTPS listener on tlsPort, alongside the plaintext
+// one started by listen, serving certFile/keyFile through a certReloader so a
+// certbot-style renewal in place is picked up without a restart (see cert_reload.go).
+// reloadInterval defaults to 1 minute when zero or negative. Errors from this listener
+// feed into the same server.errChan as the plaintext one - call listen before this, so
+// that channel already exists.
+func (server *httpServer) listenTLS(certFile, keyFile, tlsPort string, reloadInterval time.Duration) error {
+	if server.errChan == nil {
+		return fmt.Errorf("listenTLS called before listen (errChan not initialized)")
+	}
+
+	reloader, err := newCertReloader(certFile, keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load TLS certificate: %v", err)
+	}
+	if reloadInterval <= 0 {
+		reloadInterval = time.Minute
+	}
+	go reloader.watch(reloadInterval)
+
+	tlsServer := &http.Server{
+		Addr:      fmt.Sprintf(":%s", tlsPort),
+		Handler:   http.HandlerFunc(server.loggingHandler),
+		TLSConfig: &tls.Config{GetCertificate: reloader.getCertificate},
+	}
+
+	go func() {
+		log.Printf("Listening on port %s (TLS)...", tlsPort)
+		err := tlsServer.ListenAndServeTLS("", "")
+		if err != nil {
+			server.errChan <- err
+		}
+	}()
+
+	return nil
 }
 
 func (server *httpServer) handle(w http.ResponseWriter, r *http.Request) {
@@ -51,7 +208,12 @@ func (server *httpServer) handle(w http.ResponseWriter, r *http.Request) {
 		server.handleInvoke(w, r)
 	case r.Method == "GET" && r.URL.Path == "/alive":
 		server.handleAlive(w)
+	case r.Method == "GET" && r.URL.Path == "/healthz":
+		server.handleHealthz(w)
 	case server.enableReset && r.Method == "POST" && r.URL.Path == "/reset":
+		if !server.requireAdminAuth(w, r) {
+			return
+		}
 		server.handleReset(w)
 	default:
 		w.WriteHeader(http.StatusNotFound)
@@ -65,13 +227,59 @@ func (server *httpServer) handleInvoke(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if server.passwordResetThrottle != nil {
+		if email, ok := passwordResetEmail(bodyBytes); ok {
+			if allowed, retryAfter := server.passwordResetThrottle.checkAndRecord(email); !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+				w.Header().Set("Content-Type", "application/json")
+				fmt.Fprintf(w, `{"ok":false,"action_invocation_id":%q,"error_code":"password_reset_cooldown_active"}`, randomActionInvocationID())
+				return
+			}
+		}
+	}
+
+	var action, subject string
+	throttled := false
+	if server.throttler != nil {
+		action, subject, throttled = throttleKey(bodyBytes, clientIP(r))
+		if throttled {
+			if allowed, retryAfter := server.throttler.Check(action, subject); !allowed {
+				w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+				w.WriteHeader(http.StatusTooManyRequests)
+				return
+			}
+		}
+	}
+
+	rec, _ := w.(*statusRecorder)
+	if server.actionLoggerRequestIDs != nil && rec != nil && rec.requestID != "" {
+		server.actionLoggerRequestIDs.set(rec.requestID)
+		defer server.actionLoggerRequestIDs.clear()
+	}
+
+	upstreamStart := time.Now()
 	resultJSON, err := server.server.ResolveActionInvocationEndpointRequestWithBlocklist(string(bodyBytes), nil)
+	if rec != nil {
+		rec.upstreamDuration = time.Since(upstreamStart)
+	}
 	if err != nil {
-		log.Printf("[%s] invoke action error=%v\n", time.Now().Format("15:04:05.000"), err)
+		requestID := ""
+		if rec != nil {
+			requestID = rec.requestID
+		}
+		log.Printf("[%s] invoke action error request_id=%s - %v", time.Now().Format("15:04:05.000"), requestID, err)
 		w.WriteHeader(http.StatusBadRequest)
 		return
 	}
 
+	if throttled {
+		if actionResultOk(resultJSON) {
+			server.throttler.RecordSuccess(action, subject)
+		} else {
+			server.throttler.RecordFailure(action, subject)
+		}
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	w.Write([]byte(resultJSON))
 }
@@ -81,7 +289,54 @@ func (server *httpServer) handleAlive(w http.ResponseWriter) {
 	w.Write([]byte(`{"status":"alive"}`))
 }
 
+// handleHealthz synchronously probes the configured email backend's connection (if
+// it supports health checks at all - see [emailClientHealthChecker]) and reports
+// whether it's currently usable, instead of /alive's unconditional "the process is
+// up" response.
+func (server *httpServer) handleHealthz(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+
+	if server.emailHealth == nil {
+		w.Write([]byte(`{"status":"healthy"}`))
+		return
+	}
+
+	if err := server.emailHealth.ProbeNow(); err != nil {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprintf(w, `{"status":"unhealthy","error":%q}`, err.Error())
+		return
+	}
+
+	w.Write([]byte(`{"status":"healthy"}`))
+}
+
+// requireAdminAuth enforces HTTP Basic auth against server.adminUsername/
+// adminPasswordHash in front of destructive admin routes (currently just /reset, but
+// any future admin route should check this the same way). On any failure - missing
+// header, wrong username, wrong password - it writes the 401 response itself and
+// returns false, so callers can just `if !server.requireAdminAuth(w, r) { return }`.
+func (server *httpServer) requireAdminAuth(w http.ResponseWriter, r *http.Request) bool {
+	unauthorized := func() bool {
+		w.Header().Set("WWW-Authenticate", `Basic realm="tiauth"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		return false
+	}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return unauthorized()
+	}
+	// subtle.ConstantTimeCompare short-circuits on a length mismatch, which only leaks
+	// the admin username's length - bcrypt.CompareHashAndPassword below is already
+	// constant-time in the password itself, so this doesn't weaken the overall check.
+	usernameMatch := subtle.ConstantTimeCompare([]byte(username), []byte(server.adminUsername)) == 1
+	passwordMatch := bcrypt.CompareHashAndPassword([]byte(server.adminPasswordHash), []byte(password)) == nil
+	if !usernameMatch || !passwordMatch {
+		return unauthorized()
+	}
+	return true
+}
+
 func (server *httpServer) handleReset(w http.ResponseWriter) {
-	log.Printf("[%s] request=%s\n", time.Now().Format("15:04:05.000"), "reset")
 	server.storage.Clear()
 }
@@ -1,41 +1,109 @@
 package tiauth
 
 import (
-	"bufio"
+	"context"
 	"fmt"
 	"os"
+	"sort"
 	"strings"
+
+	"github.com/faroedev/faroe"
+	"github.com/tiptenbrink/tiauth-faroe/tiauth/throttle"
 )
 
+// interactiveShell is an administrative REPL over the running server, started only
+// when the operator passes --interactive. Commands are registered in shellCommands
+// rather than hard-coded in a switch, so new ones (interactive_commands.go) slot in
+// without touching the dispatch loop.
 type interactiveShell struct {
-	reader  *bufio.Reader
-	storage *storageStruct
+	lineReader lineReader
+	// lineCh is the single channel every line of input arrives on once listen has
+	// started the sole reader goroutine. confirm() receives from it directly rather
+	// than calling lineReader.ReadLine() itself, so there is never more than one
+	// goroutine reading stdin - see listen's doc comment.
+	lineCh    chan string
+	readErrCh chan error
+
+	storage          Storage
+	faroeServer      *faroe.ServerStruct
+	userStore        faroe.UserStoreInterface
+	tokenBroadcaster *TokenBroadcaster
+	emailSender      *actionsEmailSender // nil when EmailBackend is "python" or "stdout"
+	loginThrottler   *throttle.Throttler
+
 	errChan chan error
 }
 
-func newInteractiveShell(storage *storageStruct) *interactiveShell {
+func newInteractiveShell(
+	storage Storage,
+	faroeServer *faroe.ServerStruct,
+	userStore faroe.UserStoreInterface,
+	tokenBroadcaster *TokenBroadcaster,
+	emailSender *actionsEmailSender,
+	loginThrottler *throttle.Throttler,
+) *interactiveShell {
 	return &interactiveShell{
-		storage: storage,
-		reader:  bufio.NewReader(os.Stdin),
+		storage:          storage,
+		faroeServer:      faroeServer,
+		userStore:        userStore,
+		tokenBroadcaster: tokenBroadcaster,
+		emailSender:      emailSender,
+		loginThrottler:   loginThrottler,
 	}
 }
 
-func (shell *interactiveShell) listen() {
+// listen starts the shell's read loop and returns once it has been spun up in a
+// goroutine. The loop exits when ctx is canceled or stdin returns an error (most
+// commonly io.EOF). Since os.Stdin can't be interrupted mid-read, the reader
+// goroutine is left running on shutdown; it is harmless because the process is
+// exiting anyway and the goroutine only ever touches shell.lineReader and lineCh.
+//
+// shell.lineReader is constructed here, not in newInteractiveShell, so that a shell
+// built with EnableInteractive=false never puts a real terminal into raw mode -
+// newLineReader's raw-mode side effect on Linux (interactive_readline_linux.go) would
+// otherwise fire on every run, interactive or not.
+//
+// Exactly one goroutine ever calls shell.lineReader.ReadLine(): the one started
+// below. confirm() reads its y/N answer from shell.lineCh rather than calling
+// ReadLine() itself, so a destructive command's confirmation prompt can never race
+// this goroutine for ownership of stdin.
+func (shell *interactiveShell) listen(ctx context.Context) {
+	shell.lineReader = newLineReader()
+
 	fmt.Println("Interactive mode started.")
 	fmt.Println("Type 'help' for available commands.")
 	fmt.Print("> ")
 
 	errChan := make(chan error, 1)
+	shell.lineCh = make(chan string)
+	shell.readErrCh = make(chan error, 1)
 
 	go func() {
 		for {
-			line, err := shell.reader.ReadString('\n')
+			line, err := shell.lineReader.ReadLine()
 			if err != nil {
+				shell.readErrCh <- err
+				return
+			}
+			shell.lineCh <- line
+		}
+	}()
+
+	go func() {
+		for {
+			select {
+			case <-ctx.Done():
+				shell.lineReader.Close()
+				errChan <- ctx.Err()
+				return
+			case err := <-shell.readErrCh:
+				shell.lineReader.Close()
 				errChan <- err
 				return
+			case line := <-shell.lineCh:
+				shell.dispatch(strings.TrimSpace(line))
+				fmt.Print("> ")
 			}
-			command := strings.TrimSpace(line)
-			shell.handleCommand(command)
 		}
 	}()
 
@@ -44,30 +112,93 @@ func (shell *interactiveShell) listen() {
 
 func (shell *interactiveShell) showHelp() {
 	fmt.Println("Available commands:")
-	fmt.Println("  reset - Clear all data from storage")
-	fmt.Println("  help  - Show this help message")
-	fmt.Println("  exit  - Exit program")
+	names := make([]string, 0, len(shellCommands))
+	for name := range shellCommands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Printf("  %-28s %s\n", shellCommands[name].usage, shellCommands[name].help)
+	}
+	fmt.Println("  help                         Show this help message")
+	fmt.Println("  exit, quit                   Exit program")
+	fmt.Println("Destructive commands ask for y/N confirmation, or pass --yes to skip it.")
 }
 
-func (shell *interactiveShell) handleCommand(command string) {
-	switch command {
-	case "reset":
-		err := shell.storage.Clear()
-		if err != nil {
-			fmt.Printf("Error clearing storage: %v\n", err)
-		} else {
-			fmt.Println("Storage cleared successfully")
-		}
+// dispatch resolves command to a registered entry, preferring a two-word match
+// ("users show") over a one-word one ("reset") so multi-word commands are matched
+// before their first word is mistaken for a standalone command.
+func (shell *interactiveShell) dispatch(command string) {
+	fields := strings.Fields(command)
+	if len(fields) == 0 {
+		return
+	}
+
+	switch fields[0] {
 	case "help":
 		shell.showHelp()
+		return
 	case "exit", "quit":
 		fmt.Println("Exiting...")
+		shell.lineReader.Close()
 		os.Exit(0)
-	case "":
-		// Empty command, just show prompt again
-	default:
-		fmt.Printf("Unknown command: %s (type 'help' for available commands)\n", command)
 	}
 
-	fmt.Print("> ")
+	if len(fields) >= 2 {
+		if cmd, ok := shellCommands[fields[0]+" "+fields[1]]; ok {
+			shell.invoke(fields[0]+" "+fields[1], cmd, fields[2:])
+			return
+		}
+	}
+	if cmd, ok := shellCommands[fields[0]]; ok {
+		shell.invoke(fields[0], cmd, fields[1:])
+		return
+	}
+
+	fmt.Printf("Unknown command: %s (type 'help' for available commands)\n", command)
+}
+
+func (shell *interactiveShell) invoke(name string, cmd *shellCommand, args []string) {
+	confirmedByFlag, args := consumeYesFlag(args)
+
+	if cmd.destructive && !confirmedByFlag {
+		if !shell.confirm(strings.TrimSpace(name + " " + strings.Join(args, " "))) {
+			fmt.Println("Aborted.")
+			return
+		}
+	}
+
+	if err := cmd.run(shell, args); err != nil {
+		fmt.Printf("Error: %v\n", err)
+	}
+}
+
+// confirm blocks on a y/N prompt, receiving the answer from shell.lineCh - the same
+// channel the listen() goroutine feeds every line into - rather than calling
+// shell.lineReader.ReadLine() itself. dispatch (and so confirm) only ever runs on
+// that goroutine, so this is just "read the next line" with no second reader
+// racing the one in listen() for stdin.
+func (shell *interactiveShell) confirm(action string) bool {
+	fmt.Printf("%s - are you sure? [y/N] ", action)
+	select {
+	case line := <-shell.lineCh:
+		answer := strings.ToLower(strings.TrimSpace(line))
+		return answer == "y" || answer == "yes"
+	case <-shell.readErrCh:
+		return false
+	}
+}
+
+// consumeYesFlag removes a trailing "--yes"/"-y" flag from args, reporting whether
+// one was present.
+func consumeYesFlag(args []string) (found bool, remaining []string) {
+	remaining = make([]string, 0, len(args))
+	for _, arg := range args {
+		if arg == "--yes" || arg == "-y" {
+			found = true
+			continue
+		}
+		remaining = append(remaining, arg)
+	}
+	return found, remaining
 }
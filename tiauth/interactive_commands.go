@@ -0,0 +1,271 @@
+package tiauth
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/faroedev/faroe"
+)
+
+// shellCommandFunc implements one registered shell command. args has already had the
+// matched command words and any --yes/-y flag stripped.
+type shellCommandFunc func(shell *interactiveShell, args []string) error
+
+type shellCommand struct {
+	usage       string
+	help        string
+	destructive bool
+	run         shellCommandFunc
+}
+
+// shellCommands is the dispatcher table: commands are registered here rather than
+// hard-coded in a switch, so interactiveShell.dispatch stays a lookup, not a branch
+// per command.
+var shellCommands = map[string]*shellCommand{
+	"reset": {
+		usage:       "reset",
+		help:        "Clear all data from storage",
+		destructive: true,
+		run:         cmdReset,
+	},
+	"users show": {
+		usage: "users show <email>",
+		help:  "Look up a user by email address",
+		run:   cmdUsersShow,
+	},
+	"users list": {
+		usage: "users list [--limit N] [--email-prefix P]",
+		help:  "Not supported by this faroe version (no user enumeration action)",
+		run:   cmdUsersList,
+	},
+	"users disable": {
+		usage:       "users disable <email>",
+		help:        "Not supported by this faroe version (no disable action)",
+		destructive: true,
+		run:         cmdUsersSetDisabled,
+	},
+	"users enable": {
+		usage:       "users enable <email>",
+		help:        "Not supported by this faroe version (no enable action)",
+		destructive: true,
+		run:         cmdUsersSetDisabled,
+	},
+	"sessions list": {
+		usage: "sessions list <email>",
+		help:  "Not supported by faroe (sessions aren't indexed by user)",
+		run:   cmdSessionsList,
+	},
+	"sessions revoke": {
+		usage:       "sessions revoke <session-token>",
+		help:        "Revoke a single session by its session token",
+		destructive: true,
+		run:         cmdSessionsRevoke,
+	},
+	"sessions revoke-all": {
+		usage:       "sessions revoke-all <session-token>",
+		help:        "Revoke every session for the user that session token belongs to",
+		destructive: true,
+		run:         cmdSessionsRevokeAll,
+	},
+	"tokens tail": {
+		usage: "tokens tail",
+		help:  "Attach to the token broadcaster and print events until Ctrl-C",
+		run:   cmdTokensTail,
+	},
+	"email test": {
+		usage: "email test <to>",
+		help:  "Send a probe email through the configured email backend",
+		run:   cmdEmailTest,
+	},
+	"throttle reset": {
+		usage:       "throttle reset <token-or-ip>",
+		help:        "Clear login-throttle entries whose flow token or IP contains this substring",
+		destructive: true,
+		run:         cmdThrottleReset,
+	},
+}
+
+func cmdReset(shell *interactiveShell, args []string) error {
+	if err := shell.storage.Clear(); err != nil {
+		return fmt.Errorf("clearing storage: %v", err)
+	}
+	fmt.Println("Storage cleared successfully")
+	return nil
+}
+
+func cmdUsersShow(shell *interactiveShell, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: users show <email>")
+	}
+	user, err := shell.userStore.GetUserByEmailAddress(args[0])
+	if err != nil {
+		if errors.Is(err, faroe.ErrUserStoreUserNotFound) {
+			return fmt.Errorf("no user with email %q", args[0])
+		}
+		return err
+	}
+	fmt.Printf("id:            %s\n", user.Id)
+	fmt.Printf("email:         %s\n", user.EmailAddress)
+	fmt.Printf("display name:  %s\n", user.DisplayName)
+	fmt.Printf("disabled:      %v\n", user.Disabled)
+	fmt.Printf("sessions seen: %d\n", user.SessionsCounter)
+	return nil
+}
+
+func cmdUsersList(shell *interactiveShell, args []string) error {
+	return fmt.Errorf("users list is not supported: faroe.UserStoreInterface has no enumeration action, only GetUser/GetUserByEmailAddress - use 'users show <email>' for a single lookup")
+}
+
+func cmdUsersSetDisabled(shell *interactiveShell, args []string) error {
+	return fmt.Errorf("disabling/enabling users is not supported: faroe.UserStoreInterface exposes no action to update UserStruct.Disabled, even though the field exists - that would need to be added to the external user action invocation backend first")
+}
+
+func cmdSessionsList(shell *interactiveShell, args []string) error {
+	return fmt.Errorf("sessions list is not supported: faroe only addresses sessions by their own session token (get_session/delete_session/delete_all_sessions), there is no index from a user back to their sessions - use 'sessions revoke <session-token>' or 'sessions revoke-all <session-token>'")
+}
+
+func cmdSessionsRevoke(shell *interactiveShell, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: sessions revoke <session-token>")
+	}
+	_, errorCode, err := shell.callFaroeAction(faroe.ActionDeleteSession, map[string]any{"session_token": args[0]})
+	if err != nil {
+		return err
+	}
+	if errorCode != "" {
+		return fmt.Errorf("delete_session failed: %s", errorCode)
+	}
+	fmt.Println("Session revoked")
+	return nil
+}
+
+func cmdSessionsRevokeAll(shell *interactiveShell, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: sessions revoke-all <session-token>")
+	}
+	_, errorCode, err := shell.callFaroeAction(faroe.ActionDeleteAllSessions, map[string]any{"session_token": args[0]})
+	if err != nil {
+		return err
+	}
+	if errorCode != "" {
+		return fmt.Errorf("delete_all_sessions failed: %s", errorCode)
+	}
+	fmt.Println("All sessions for that user revoked")
+	return nil
+}
+
+// callFaroeAction invokes action directly against the in-process faroe server, using
+// the same action-invocation-endpoint JSON protocol http_server.go's handleInvoke
+// drives over HTTP. errorCode is set (and err nil) for a well-formed action failure
+// (e.g. "session_not_found"); err is set for transport/decode failures.
+func (shell *interactiveShell) callFaroeAction(action string, arguments map[string]any) (values map[string]any, errorCode string, err error) {
+	bodyJSON, err := json.Marshal(struct {
+		Action    string         `json:"action"`
+		Arguments map[string]any `json:"arguments"`
+	}{Action: action, Arguments: arguments})
+	if err != nil {
+		return nil, "", err
+	}
+
+	resultJSON, err := shell.faroeServer.ResolveActionInvocationEndpointRequestWithBlocklist(string(bodyJSON), nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("action invocation failed: %v", err)
+	}
+
+	var result struct {
+		Ok        bool           `json:"ok"`
+		ErrorCode string         `json:"error_code"`
+		Values    map[string]any `json:"values"`
+	}
+	if err := json.Unmarshal([]byte(resultJSON), &result); err != nil {
+		return nil, "", fmt.Errorf("failed to parse action invocation result: %v", err)
+	}
+	if !result.Ok {
+		return nil, result.ErrorCode, nil
+	}
+	return result.Values, "", nil
+}
+
+func cmdTokensTail(shell *interactiveShell, args []string) error {
+	if shell.tokenBroadcaster == nil || shell.tokenBroadcaster.socketPath == "" {
+		return fmt.Errorf("token broadcasting is disabled (set FAROE_TOKEN_SOCKET_PATH to enable)")
+	}
+
+	conn, err := net.Dial("unix", shell.tokenBroadcaster.socketPath)
+	if err != nil {
+		return fmt.Errorf("failed to attach to token socket: %v", err)
+	}
+	defer conn.Close()
+
+	// Ctrl-C here is meant to stop the tail, not the whole shell, so intercept SIGINT
+	// only for the duration of this command and restore the default disposition after.
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, os.Interrupt)
+	defer signal.Stop(sigCh)
+
+	lineCh := make(chan string)
+	doneCh := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lineCh <- scanner.Text()
+		}
+		doneCh <- scanner.Err()
+	}()
+
+	fmt.Println("Tailing token events, press Ctrl-C to stop...")
+	for {
+		select {
+		case <-sigCh:
+			fmt.Println("Stopped tailing.")
+			return nil
+		case line := <-lineCh:
+			fmt.Println(line)
+		case err := <-doneCh:
+			if err != nil {
+				return fmt.Errorf("token socket connection closed: %v", err)
+			}
+			fmt.Println("Token socket connection closed by server.")
+			return nil
+		}
+	}
+}
+
+func cmdEmailTest(shell *interactiveShell, args []string) error {
+	if shell.emailSender == nil {
+		return fmt.Errorf("email test requires FAROE_EMAIL_BACKEND to be smtp, mailgun, ses, or webhook")
+	}
+	if len(args) != 1 {
+		return fmt.Errorf("usage: email test <to>")
+	}
+
+	now := time.Now().Format(time.RFC1123)
+	body := fmt.Sprintf("This is a probe email sent from tiauth's interactive shell at %s.", now)
+	if err := shell.emailSender.SendEmail(args[0], "tiauth email probe", body); err != nil {
+		return fmt.Errorf("sending probe email: %v", err)
+	}
+	fmt.Printf("Probe email sent to %s\n", args[0])
+	return nil
+}
+
+func cmdThrottleReset(shell *interactiveShell, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: throttle reset <token-or-ip>")
+	}
+	cleared := shell.loginThrottler.ResetMatching(args[0])
+	fmt.Printf("Cleared %d throttle entr%s matching %q\n", cleared, plural(cleared), args[0])
+	return nil
+}
+
+func plural(n int) string {
+	if n == 1 {
+		return "y"
+	}
+	return "ies"
+}
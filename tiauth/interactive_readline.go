@@ -0,0 +1,61 @@
+package tiauth
+
+import (
+	"bufio"
+	"os"
+)
+
+// lineReader reads one line of shell input at a time. newLineReader (platform-specific,
+// see interactive_readline_linux.go / interactive_readline_other.go) picks the richest
+// implementation available: a raw-mode reader with tab completion when stdin is a Linux
+// terminal, falling back to bufioLineReader everywhere else (piped input, non-Linux).
+type lineReader interface {
+	// ReadLine returns the next line with its trailing newline stripped. Returns
+	// io.EOF (or another error) when input is exhausted, matching bufio.Reader.
+	ReadLine() (string, error)
+
+	// Close releases anything ReadLine acquired (e.g. a terminal's raw mode). Safe
+	// to call multiple times. Always call it before os.Exit, which skips defers and
+	// so would otherwise skip this too.
+	Close()
+}
+
+// bufioLineReader is the plain line-buffered fallback: no tab completion, but works
+// identically whether stdin is a terminal or a pipe.
+type bufioLineReader struct {
+	reader *bufio.Reader
+}
+
+func newBufioLineReader() *bufioLineReader {
+	return &bufioLineReader{reader: bufio.NewReader(os.Stdin)}
+}
+
+func (r *bufioLineReader) ReadLine() (string, error) {
+	line, err := r.reader.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	return trimNewline(line), nil
+}
+
+// Close is a no-op: a bufio.Reader over stdin has no mode to restore.
+func (r *bufioLineReader) Close() {}
+
+func trimNewline(line string) string {
+	for len(line) > 0 && (line[len(line)-1] == '\n' || line[len(line)-1] == '\r') {
+		line = line[:len(line)-1]
+	}
+	return line
+}
+
+// commandWords lists the first-word and "first second"-word completions the raw-mode
+// reader offers on Tab, derived from shellCommands plus the two bare verbs it doesn't
+// cover.
+func commandWords() []string {
+	words := make([]string, 0, len(shellCommands)+3)
+	words = append(words, "help", "exit", "quit")
+	for name := range shellCommands {
+		words = append(words, name)
+	}
+	return words
+}
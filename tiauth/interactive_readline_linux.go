@@ -0,0 +1,162 @@
+//go:build linux
+
+package tiauth
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"golang.org/x/sys/unix"
+)
+
+// newLineReader uses a raw-mode reader with Tab completion when stdin is a real
+// terminal, and falls back to the plain line-buffered reader otherwise (piped input,
+// e.g. under a supervisor or in tests).
+func newLineReader() lineReader {
+	reader, err := newRawLineReader(int(os.Stdin.Fd()))
+	if err != nil {
+		return newBufioLineReader()
+	}
+	return reader
+}
+
+// rawLineReader puts stdin into non-canonical, non-echoing mode and implements just
+// enough line editing itself (printable chars, backspace, Tab completion, Enter) to
+// stand in for readline. ISIG is left enabled, so Ctrl-C still raises SIGINT at the OS
+// level exactly as it would in canonical mode - commands like "tokens tail" rely on
+// that via signal.Notify rather than on this reader observing a Ctrl-C byte.
+type rawLineReader struct {
+	fd       int
+	original unix.Termios
+	restored bool
+}
+
+func newRawLineReader(fd int) (*rawLineReader, error) {
+	original, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		// Not a terminal (e.g. stdin is a pipe) - let the caller fall back.
+		return nil, err
+	}
+
+	raw := *original
+	raw.Lflag &^= unix.ICANON | unix.ECHO
+	raw.Cc[unix.VMIN] = 1
+	raw.Cc[unix.VTIME] = 0
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &raw); err != nil {
+		return nil, err
+	}
+
+	return &rawLineReader{fd: fd, original: *original}, nil
+}
+
+// Close restores the terminal's original termios settings. Safe to call more than
+// once (e.g. once from ReadLine's EOF path and again from the caller's shutdown
+// path) - only the first call has any effect.
+func (r *rawLineReader) Close() {
+	if r.restored {
+		return
+	}
+	r.restored = true
+	unix.IoctlSetTermios(r.fd, unix.TCSETS, &r.original)
+}
+
+func (r *rawLineReader) ReadLine() (string, error) {
+	var buf []rune
+	b := make([]byte, 1)
+
+	for {
+		n, err := os.Stdin.Read(b)
+		if err != nil {
+			r.Close()
+			return "", err
+		}
+		if n == 0 {
+			continue
+		}
+
+		switch b[0] {
+		case '\r', '\n':
+			fmt.Print("\r\n")
+			return string(buf), nil
+		case 127, '\b': // backspace (most terminals send DEL, 127)
+			if len(buf) > 0 {
+				buf = buf[:len(buf)-1]
+				fmt.Print("\b \b")
+			}
+		case '\t':
+			buf = r.complete(buf)
+		case 4: // Ctrl-D
+			if len(buf) == 0 {
+				fmt.Print("\r\n")
+				r.Close()
+				return "", io.EOF
+			}
+		default:
+			if b[0] >= 0x20 {
+				buf = append(buf, rune(b[0]))
+				fmt.Printf("%c", b[0])
+			}
+		}
+	}
+}
+
+// complete replaces the word currently being typed with its unique completion among
+// the registered command words, or lists the candidates and reprints the prompt line
+// when there's more than one.
+func (r *rawLineReader) complete(buf []rune) []rune {
+	line := string(buf)
+	fields := strings.Fields(line)
+	trailingSpace := strings.HasSuffix(line, " ")
+
+	var prefix string
+	if len(fields) > 0 && !trailingSpace {
+		prefix = fields[len(fields)-1]
+	}
+
+	candidates := r.candidatesFor(fields, trailingSpace, prefix)
+	if len(candidates) == 0 {
+		return buf
+	}
+	if len(candidates) == 1 {
+		completed := candidates[0][len(prefix):]
+		fmt.Print(completed)
+		return append(buf, []rune(completed)...)
+	}
+
+	sort.Strings(candidates)
+	fmt.Print("\r\n" + strings.Join(candidates, "  ") + "\r\n> " + line)
+	return buf
+}
+
+// candidatesFor matches prefix against the first word (if fields is empty or we're
+// still typing the first word) or the second word of a known multi-word command.
+func (r *rawLineReader) candidatesFor(fields []string, trailingSpace bool, prefix string) []string {
+	words := commandWords()
+
+	typingFirstWord := len(fields) == 0 || (len(fields) == 1 && !trailingSpace)
+	if typingFirstWord {
+		seen := map[string]bool{}
+		var out []string
+		for _, w := range words {
+			first := strings.Fields(w)[0]
+			if strings.HasPrefix(first, prefix) && !seen[first] {
+				seen[first] = true
+				out = append(out, first)
+			}
+		}
+		return out
+	}
+
+	firstWord := fields[0]
+	var out []string
+	for _, w := range words {
+		parts := strings.Fields(w)
+		if len(parts) == 2 && parts[0] == firstWord && strings.HasPrefix(parts[1], prefix) {
+			out = append(out, parts[1])
+		}
+	}
+	return out
+}
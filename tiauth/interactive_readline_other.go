@@ -0,0 +1,10 @@
+//go:build !linux
+
+package tiauth
+
+// newLineReader returns the plain line-buffered reader outside Linux: the raw-mode
+// tab-completing reader in interactive_readline_linux.go depends on Linux termios
+// ioctls this platform doesn't have.
+func newLineReader() lineReader {
+	return newBufioLineReader()
+}
@@ -0,0 +1,273 @@
+package tiauth
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// renderMarkdownToHTML converts a minimal subset of Markdown to HTML: headers,
+// paragraphs, bold/italic, inline code, links, and unordered/ordered lists, plus a
+// Smartypants-style pass over the result (straight quotes/dashes/ellipsis to their
+// typographic equivalents). There's no markdown library vendored in this module (no
+// network access to fetch gomarkdown), so this is hand-rolled to cover what the email
+// templates actually use rather than the full CommonMark spec.
+func renderMarkdownToHTML(src string) string {
+	blocks := splitMarkdownBlocks(src)
+
+	var html strings.Builder
+	for _, block := range blocks {
+		renderMarkdownBlock(&html, block)
+	}
+
+	return html.String()
+}
+
+// markdownBlock is one paragraph, heading, or contiguous list, as produced by
+// splitMarkdownBlocks.
+type markdownBlock struct {
+	kind  string // "heading", "paragraph", "ul", "ol"
+	level int    // heading level 1-6
+	lines []string
+}
+
+var markdownHeadingRe = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+var markdownULRe = regexp.MustCompile(`^[-*]\s+(.*)$`)
+var markdownOLRe = regexp.MustCompile(`^\d+\.\s+(.*)$`)
+
+func splitMarkdownBlocks(src string) []markdownBlock {
+	var blocks []markdownBlock
+	var current markdownBlock
+
+	flush := func() {
+		if len(current.lines) > 0 {
+			blocks = append(blocks, current)
+		}
+		current = markdownBlock{}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n") {
+		trimmed := strings.TrimSpace(line)
+
+		if trimmed == "" {
+			flush()
+			continue
+		}
+
+		if m := markdownHeadingRe.FindStringSubmatch(trimmed); m != nil {
+			flush()
+			blocks = append(blocks, markdownBlock{kind: "heading", level: len(m[1]), lines: []string{m[2]}})
+			continue
+		}
+
+		if m := markdownULRe.FindStringSubmatch(trimmed); m != nil {
+			if current.kind != "ul" {
+				flush()
+				current.kind = "ul"
+			}
+			current.lines = append(current.lines, m[1])
+			continue
+		}
+
+		if m := markdownOLRe.FindStringSubmatch(trimmed); m != nil {
+			if current.kind != "ol" {
+				flush()
+				current.kind = "ol"
+			}
+			current.lines = append(current.lines, m[1])
+			continue
+		}
+
+		if current.kind != "paragraph" && current.kind != "" {
+			flush()
+		}
+		current.kind = "paragraph"
+		current.lines = append(current.lines, trimmed)
+	}
+	flush()
+
+	return blocks
+}
+
+func renderMarkdownBlock(html *strings.Builder, block markdownBlock) {
+	switch block.kind {
+	case "heading":
+		fmt.Fprintf(html, "<h%d>%s</h%d>", block.level, renderMarkdownInline(block.lines[0]), block.level)
+	case "ul":
+		html.WriteString("<ul>")
+		for _, item := range block.lines {
+			fmt.Fprintf(html, "<li>%s</li>", renderMarkdownInline(item))
+		}
+		html.WriteString("</ul>")
+	case "ol":
+		html.WriteString("<ol>")
+		for _, item := range block.lines {
+			fmt.Fprintf(html, "<li>%s</li>", renderMarkdownInline(item))
+		}
+		html.WriteString("</ol>")
+	case "paragraph":
+		fmt.Fprintf(html, "<p>%s</p>", renderMarkdownInline(strings.Join(block.lines, "<br>")))
+	}
+}
+
+// markdownMetachars maps each character this hand-rolled parser treats as markdown
+// syntax to a Unicode private-use-area stand-in. escapeMarkdownMetachars uses these to
+// neutralize untrusted data (e.g. a user's display name) before it's interpolated into
+// a .md email template: html.EscapeString (see renderMarkdownInline) only escapes
+// "<>&'\"", so without this step a display name like "[click](javascript:alert(1))"
+// would still be parsed as a live link by markdownLinkRe. '\n'/'\r' are included too -
+// splitMarkdownBlocks works line by line, so without escaping them a display name like
+// "Alice\n# Account compromised" would start a new heading/list block of its own. '#'
+// and '-' are included for the same reason, covering untrusted data that lands at the
+// very start of a line on its own (e.g. a template with "{{.Greeting}}" alone on its
+// first line) rather than via an embedded newline. The stand-ins are restored by
+// renderBodies (see smtp_sender.go) once every markdown-syntax regex has already run
+// on the rendered body, so they can't be mistaken for syntax themselves.
+var markdownMetachars = map[rune]rune{
+	'[':  '',
+	']':  '',
+	'(':  '',
+	')':  '',
+	'*':  '',
+	'_':  '',
+	'`':  '',
+	'#':  '',
+	'-':  '',
+	'\n': '',
+	'\r': '',
+}
+
+// escapeMarkdownMetachars replaces markdown-significant characters in untrusted data
+// with inert stand-ins - see markdownMetachars. It also neutralizes any of the
+// stand-in code points themselves if they already occur literally in s (replacing
+// them with the Unicode replacement character), so a display name that happens to
+// contain one of those code points can't later be mistaken by
+// unescapeMarkdownMetachars for a stand-in this function inserted and rewritten back
+// into a live markdown metacharacter.
+func escapeMarkdownMetachars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if standIn, ok := markdownMetachars[r]; ok {
+			return standIn
+		}
+		if _, ok := markdownMetacharsReverse[r]; ok {
+			return '�'
+		}
+		return r
+	}, s)
+}
+
+// markdownMetacharsReverse is markdownMetachars with keys and values swapped, so
+// unescapeMarkdownMetachars can look up the original character in O(1) instead of
+// scanning every entry for each rune of the string.
+var markdownMetacharsReverse = func() map[rune]rune {
+	reverse := make(map[rune]rune, len(markdownMetachars))
+	for original, standIn := range markdownMetachars {
+		reverse[standIn] = original
+	}
+	return reverse
+}()
+
+// unescapeMarkdownMetachars restores the stand-ins escapeMarkdownMetachars inserted
+// back to their literal characters.
+func unescapeMarkdownMetachars(s string) string {
+	return strings.Map(func(r rune) rune {
+		if original, ok := markdownMetacharsReverse[r]; ok {
+			return original
+		}
+		return r
+	}, s)
+}
+
+var (
+	markdownLinkRe     = regexp.MustCompile(`\[([^\]]+)\]\(([^)]+)\)`)
+	markdownBoldStarRe = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	markdownBoldUscRe  = regexp.MustCompile(`__(.+?)__`)
+	// markdownItalicStarRe/markdownItalicUscRe require a non-word character (or
+	// string start/end) on both sides of the delimiter, so "jane_q_doe" or "a*b*c"
+	// isn't mistaken for emphasis - the boundary characters are captured and put
+	// back in the replacement since Go's RE2 engine has no lookaround to check them
+	// without consuming them.
+	markdownItalicStarRe = regexp.MustCompile(`(^|[^\w*])\*([^\s*](?:[^*]*[^\s*])?)\*([^\w*]|$)`)
+	markdownItalicUscRe  = regexp.MustCompile(`(^|[^\w_])_([^\s_](?:[^_]*[^\s_])?)_([^\w_]|$)`)
+	markdownCodeRe       = regexp.MustCompile("`([^`]+)`")
+)
+
+// renderMarkdownInline applies inline formatting (links, bold, italic, code) within
+// a single block of text. Smartypants runs first, while s is still plain text, then
+// the whole block is HTML-escaped before any tags are inserted - template data (e.g.
+// a user's display name) flows into this text unescaped, so this is what keeps it
+// from breaking out of the generated markup. Order matters after that: links and
+// code are resolved before bold/italic, and bold before italic, so "**" isn't
+// mistaken for two "*" italics - Go's RE2 engine doesn't support backreferences, so
+// "**"/"__" and "*"/"_" each get their own pattern rather than one pattern capturing
+// the matched delimiter. Any escapeMarkdownMetachars stand-ins (see markdown.go) are
+// left in place here and restored later, once, by renderBodies in smtp_sender.go.
+func renderMarkdownInline(s string) string {
+	s = smartypants(s)
+	s = html.EscapeString(s)
+	s = markdownLinkRe.ReplaceAllString(s, `<a href="$2">$1</a>`)
+	s = markdownCodeRe.ReplaceAllString(s, "<code>$1</code>")
+	s = markdownBoldStarRe.ReplaceAllString(s, "<strong>$1</strong>")
+	s = markdownBoldUscRe.ReplaceAllString(s, "<strong>$1</strong>")
+	s = markdownItalicStarRe.ReplaceAllString(s, "${1}<em>$2</em>$3")
+	s = markdownItalicUscRe.ReplaceAllString(s, "${1}<em>$2</em>$3")
+	return s
+}
+
+// smartypants replaces straight quotes, double hyphens, and ellipses with their
+// typographic equivalents, the same transform gomarkdown's Smartypants extension
+// applies.
+func smartypants(s string) string {
+	replacer := strings.NewReplacer(
+		"...", "…",
+		"---", "—",
+		"--", "–",
+	)
+	s = replacer.Replace(s)
+	s = smartypantsQuoteRe.ReplaceAllStringFunc(s, smartypantsQuote)
+	return s
+}
+
+var smartypantsQuoteRe = regexp.MustCompile(`"([^"]*)"|'([^']*)'`)
+
+func smartypantsQuote(match string) string {
+	if strings.HasPrefix(match, `"`) {
+		return "“" + match[1:len(match)-1] + "”"
+	}
+	return "‘" + match[1:len(match)-1] + "’"
+}
+
+// stripMarkdownSyntax renders src down to plain text for the text/plain part of a
+// multipart/alternative message: headers, emphasis markers, and code fences are
+// removed, and links are rewritten as "text (url)" so the destination survives
+// without HTML.
+func stripMarkdownSyntax(src string) string {
+	blocks := splitMarkdownBlocks(src)
+
+	var out []string
+	for _, block := range blocks {
+		switch block.kind {
+		case "heading":
+			out = append(out, stripMarkdownInline(block.lines[0]))
+		case "ul", "ol":
+			for _, item := range block.lines {
+				out = append(out, "- "+stripMarkdownInline(item))
+			}
+		case "paragraph":
+			out = append(out, stripMarkdownInline(strings.Join(block.lines, "\n")))
+		}
+	}
+
+	return strings.Join(out, "\n\n")
+}
+
+func stripMarkdownInline(s string) string {
+	s = markdownLinkRe.ReplaceAllString(s, "$1 ($2)")
+	s = markdownCodeRe.ReplaceAllString(s, "$1")
+	s = markdownBoldStarRe.ReplaceAllString(s, "$1")
+	s = markdownBoldUscRe.ReplaceAllString(s, "$1")
+	s = markdownItalicStarRe.ReplaceAllString(s, "${1}$2$3")
+	s = markdownItalicUscRe.ReplaceAllString(s, "${1}$2$3")
+	return s
+}
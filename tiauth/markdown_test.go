@@ -0,0 +1,45 @@
+package tiauth
+
+import "testing"
+
+func TestEscapeMarkdownMetacharsRoundTrip(t *testing.T) {
+	tests := []string{
+		"jane_doe",
+		"[click](javascript:alert(1))",
+		"Alice\n# Account compromised - click here",
+		"Bob\n- fake notice",
+		"plain text with no metachars",
+	}
+
+	for _, s := range tests {
+		got := unescapeMarkdownMetachars(escapeMarkdownMetachars(s))
+		if got != s {
+			t.Errorf("round trip for %q: got %q", s, got)
+		}
+	}
+}
+
+func TestEscapeMarkdownMetacharsNeutralizesPreexistingStandIns(t *testing.T) {
+	for _, standIn := range markdownMetachars {
+		input := "before" + string(standIn) + "after"
+		escaped := escapeMarkdownMetachars(input)
+		if unescapeMarkdownMetachars(escaped) == input {
+			t.Errorf("pre-existing stand-in %U round-tripped back to itself instead of being neutralized", standIn)
+		}
+	}
+}
+
+func TestRenderMarkdownToHTMLEscapesInjectedMarkup(t *testing.T) {
+	displayName := "[click](javascript:alert(1))"
+	escaped := escapeMarkdownMetachars(displayName)
+
+	html := unescapeMarkdownMetachars(renderMarkdownToHTML(escaped))
+	if html != "<p>"+displayName+"</p>" {
+		t.Errorf("expected the bracket/paren link syntax to render as inert text, got %q", html)
+	}
+
+	block := unescapeMarkdownMetachars(renderMarkdownToHTML(escapeMarkdownMetachars("Alice\n# Account compromised")))
+	if block != "<p>Alice\n# Account compromised</p>" {
+		t.Errorf("expected the embedded heading syntax to stay inside one paragraph, got %q", block)
+	}
+}
@@ -0,0 +1,140 @@
+package tiauth
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"time"
+)
+
+// ntfyConfig configures the ntfy-compatible push notifier: a lightweight alternative
+// to SMTP for self-hosters who don't want to run any mail infrastructure at all (see
+// https://ntfy.sh for the protocol this targets).
+type ntfyConfig struct {
+	// topicURLTemplate is the per-recipient ntfy topic URL to POST each notification
+	// to, with "{email}" replaced by the recipient's address - e.g.
+	// "https://ntfy.example.com/user-{email}". Required; app.go's Run rejects a
+	// "push" EmailBackend with an empty FAROE_PUSH_URL before this is ever built.
+	topicURLTemplate string
+	// authUser/authPassword, if set, are sent as HTTP Basic auth credentials - ntfy
+	// supports protecting a topic this way.
+	authUser     string
+	authPassword string
+	// requestTimeout bounds each POST. Zero means no timeout.
+	requestTimeout time.Duration
+}
+
+// PushNotifier implements faroe.EmailSenderInterface by POSTing each notification to
+// an ntfy-compatible push topic instead of sending an email, per ntfy's "publish by
+// POST" API (https://docs.ntfy.sh/publish/): the request body is the message text,
+// and Title/Priority/Tags headers carry the rest. app.go selects it when
+// Config.EmailBackend is "push" (the default once FAROE_PUSH_URL is set and
+// DisableSMTP/FAROE_DISABLE_SMTP is true).
+type PushNotifier struct {
+	config           ntfyConfig
+	httpClient       *http.Client
+	tokenBroadcaster *TokenBroadcaster
+}
+
+// newPushNotifier constructs a PushNotifier. tokenBroadcaster may be nil, the same as
+// actionsEmailSender's.
+func newPushNotifier(config ntfyConfig, tokenBroadcaster *TokenBroadcaster) *PushNotifier {
+	return &PushNotifier{
+		config:           config,
+		httpClient:       &http.Client{Timeout: config.requestTimeout},
+		tokenBroadcaster: tokenBroadcaster,
+	}
+}
+
+// topicURL renders config.topicURLTemplate for one recipient, the same way
+// buildUnsubscribeURLBuilder's "{email}" template does - query-escaped, since an
+// unescaped email local-part can contain URL path/query metacharacters
+// (faroe.EmailSenderInterface only validates the address as an email, not as a safe
+// URL path segment).
+func (p *PushNotifier) topicURL(emailAddress string) string {
+	return strings.ReplaceAll(p.config.topicURLTemplate, "{email}", neturl.QueryEscape(emailAddress))
+}
+
+// publish POSTs one notification to emailAddress's ntfy topic, per
+// https://docs.ntfy.sh/publish/#publish-as-body: message is the request body; title,
+// priority (ntfy's 1-5 scale as a string, e.g. "high"), and tags (a comma-separated
+// list of ntfy emoji shortcodes) go in headers. An empty priority/tags is omitted
+// rather than sent as an empty header.
+func (p *PushNotifier) publish(emailAddress, title, message, priority, tags string) error {
+	req, err := http.NewRequest(http.MethodPost, p.topicURL(emailAddress), strings.NewReader(message))
+	if err != nil {
+		return fmt.Errorf("failed to build ntfy request: %v", err)
+	}
+	req.Header.Set("Title", title)
+	if priority != "" {
+		req.Header.Set("Priority", priority)
+	}
+	if tags != "" {
+		req.Header.Set("Tags", tags)
+	}
+	if p.config.authUser != "" {
+		req.SetBasicAuth(p.config.authUser, p.config.authPassword)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to POST ntfy notification: %v", err)
+	}
+	defer resp.Body.Close()
+
+	respBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("failed to read ntfy response: %v", err)
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("ntfy topic returned status %d: %s", resp.StatusCode, string(respBody))
+	}
+	return nil
+}
+
+func (p *PushNotifier) SendSignupEmailAddressVerificationCode(emailAddress string, emailAddressVerificationCode string) error {
+	if p.tokenBroadcaster != nil {
+		p.tokenBroadcaster.BroadcastSignupVerification(emailAddress, emailAddressVerificationCode)
+	}
+	return p.publish(emailAddress, "Signup verification code",
+		fmt.Sprintf("Your email address verification code is %s.", emailAddressVerificationCode),
+		"default", "")
+}
+
+func (p *PushNotifier) SendUserEmailAddressUpdateEmailVerificationCode(emailAddress string, displayName string, emailAddressVerificationCode string) error {
+	if p.tokenBroadcaster != nil {
+		p.tokenBroadcaster.BroadcastEmailUpdateVerification(emailAddress, emailAddressVerificationCode)
+	}
+	return p.publish(emailAddress, "Email update verification code",
+		fmt.Sprintf("Your email update verification code is %s.", emailAddressVerificationCode),
+		"default", "")
+}
+
+func (p *PushNotifier) SendUserPasswordResetTemporaryPassword(emailAddress string, displayName string, temporaryPassword string) error {
+	if p.tokenBroadcaster != nil {
+		p.tokenBroadcaster.BroadcastPasswordReset(emailAddress, temporaryPassword)
+	}
+	return p.publish(emailAddress, "Password reset temporary password",
+		fmt.Sprintf("Your password reset temporary password is %s.", temporaryPassword),
+		"high", "closed_lock_with_key")
+}
+
+func (p *PushNotifier) SendUserSignedInNotification(emailAddress string, displayName string, sendTime time.Time) error {
+	return p.publish(emailAddress, "Sign-in detected",
+		fmt.Sprintf("We detected a sign-in to your account at %s (UTC).", sendTime.UTC().Format("January 2, 2006 15:04:05")),
+		"default", "closed_lock_with_key")
+}
+
+func (p *PushNotifier) SendUserPasswordUpdatedNotification(emailAddress string, displayName string, sendTime time.Time) error {
+	return p.publish(emailAddress, "Password updated",
+		fmt.Sprintf("Your account password was updated at %s (UTC).", sendTime.UTC().Format("January 2, 2006 15:04:05")),
+		"default", "closed_lock_with_key")
+}
+
+func (p *PushNotifier) SendUserEmailAddressUpdatedNotification(emailAddress string, displayName string, newEmailAddress string, sendTime time.Time) error {
+	return p.publish(emailAddress, "Email updated",
+		fmt.Sprintf("Your account email address was updated to %s at %s (UTC).", newEmailAddress, sendTime.UTC().Format("January 2, 2006 15:04:05")),
+		"default", "closed_lock_with_key")
+}
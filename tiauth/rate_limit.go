@@ -0,0 +1,128 @@
+package tiauth
+
+import (
+	"context"
+	"net/mail"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRateLimiterDomains bounds domainRateLimiter.buckets so a long-running process
+// fed many distinct (or malformed) recipient domains doesn't grow the map forever;
+// the single oldest bucket is evicted once this is reached.
+const maxRateLimiterDomains = 10000
+
+// domainRateLimiter is a token-bucket limiter keyed on the recipient's email domain,
+// so a burst of sends to one domain (e.g. a flood of password resets) can't trip
+// that provider's per-domain rate cap while other domains keep flowing normally.
+// There's no golang.org/x/time/rate vendored in this module, so the bucket is
+// hand-rolled.
+type domainRateLimiter struct {
+	ratePerSecond float64
+	burst         float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens     float64
+	lastRefill time.Time
+}
+
+// newDomainRateLimiter returns a limiter allowing ratePerSecond sustained sends per
+// domain, with up to burst sends allowed immediately before that rate kicks in. A
+// burst below 1 would make even the very first send to a domain wait out a full
+// interval, so it's floored at 1.
+func newDomainRateLimiter(ratePerSecond, burst float64) *domainRateLimiter {
+	if burst < 1 {
+		burst = 1
+	}
+	return &domainRateLimiter{
+		ratePerSecond: ratePerSecond,
+		burst:         burst,
+		buckets:       make(map[string]*tokenBucket),
+	}
+}
+
+// Wait blocks until a token is available for email's domain, or ctx is canceled.
+func (l *domainRateLimiter) Wait(ctx context.Context, email string) error {
+	domain := domainOf(email)
+	for {
+		wait, ok := l.reserve(domain)
+		if ok {
+			return nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		}
+	}
+}
+
+// reserve takes a token for domain if one is available (refilling the bucket for
+// elapsed time first) and reports true, or reports how long the caller should wait
+// before a token will be available.
+func (l *domainRateLimiter) reserve(domain string) (wait time.Duration, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	b, exists := l.buckets[domain]
+	if !exists {
+		if len(l.buckets) >= maxRateLimiterDomains {
+			l.evictOldestLocked()
+		}
+		b = &tokenBucket{tokens: l.burst, lastRefill: now}
+		l.buckets[domain] = b
+	}
+
+	elapsed := now.Sub(b.lastRefill).Seconds()
+	b.tokens = min(l.burst, b.tokens+elapsed*l.ratePerSecond)
+	b.lastRefill = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return 0, true
+	}
+
+	deficit := 1 - b.tokens
+	return time.Duration(deficit / l.ratePerSecond * float64(time.Second)), false
+}
+
+// evictOldestLocked removes the bucket with the oldest lastRefill from l.buckets.
+// Callers must hold l.mu.
+func (l *domainRateLimiter) evictOldestLocked() {
+	var oldestDomain string
+	var oldestTime time.Time
+	for domain, b := range l.buckets {
+		if oldestDomain == "" || b.lastRefill.Before(oldestTime) {
+			oldestDomain = domain
+			oldestTime = b.lastRefill
+		}
+	}
+	if oldestDomain != "" {
+		delete(l.buckets, oldestDomain)
+	}
+}
+
+// domainOf returns the lowercased domain of email. email may be a bare address
+// ("user@domain.com") or an RFC 5322 mailbox ("Name <user@domain.com>") - the latter
+// is what sendToRecipientCtx builds whenever a displayName is set, and it must bucket
+// identically to the bare form or the same real domain gets split across two buckets.
+// If email isn't a recognizable address, the lowercased string itself is used so a
+// malformed address still gets its own bucket instead of panicking or going unlimited.
+func domainOf(email string) string {
+	if addr, err := mail.ParseAddress(email); err == nil {
+		email = addr.Address
+	}
+	if idx := strings.LastIndex(email, "@"); idx >= 0 {
+		return strings.ToLower(email[idx+1:])
+	}
+	return strings.ToLower(email)
+}
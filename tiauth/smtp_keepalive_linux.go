@@ -0,0 +1,41 @@
+//go:build linux
+
+package tiauth
+
+import (
+	"fmt"
+	"net"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// tuneTCPKeepalive sets TCP_KEEPCNT and TCP_KEEPINTVL on conn's underlying socket,
+// giving finer-grained control over dead-connection detection than net.Dialer.KeepAlive
+// alone provides. probes or interval of zero leaves the corresponding OS default in place.
+func tuneTCPKeepalive(conn *net.TCPConn, probes int, interval time.Duration) error {
+	rawConn, err := conn.SyscallConn()
+	if err != nil {
+		return fmt.Errorf("failed to get raw connection: %v", err)
+	}
+
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		if probes > 0 {
+			if sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_KEEPCNT, probes); sockErr != nil {
+				return
+			}
+		}
+		if interval > 0 {
+			seconds := int(interval.Round(time.Second).Seconds())
+			if seconds < 1 {
+				seconds = 1
+			}
+			sockErr = unix.SetsockoptInt(int(fd), unix.IPPROTO_TCP, unix.TCP_KEEPINTVL, seconds)
+		}
+	})
+	if err != nil {
+		return fmt.Errorf("failed to control raw connection: %v", err)
+	}
+	return sockErr
+}
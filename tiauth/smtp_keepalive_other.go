@@ -0,0 +1,14 @@
+//go:build !linux
+
+package tiauth
+
+import (
+	"net"
+	"time"
+)
+
+// tuneTCPKeepalive is a no-op outside Linux: TCP_KEEPCNT/TCP_KEEPINTVL tuning is not
+// implemented for this platform, so callers fall back to net.Dialer.KeepAlive only.
+func tuneTCPKeepalive(conn *net.TCPConn, probes int, interval time.Duration) error {
+	return nil
+}
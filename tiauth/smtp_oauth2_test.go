@@ -0,0 +1,181 @@
+package tiauth
+
+import (
+	"bufio"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strings"
+	"testing"
+)
+
+// fakeSMTPServer is a minimal in-process SMTP server, just capable enough to drive
+// an EHLO/AUTH XOAUTH2/QUIT exchange without TLS. It records the AUTH command's
+// decoded initial response for the test to inspect.
+type fakeSMTPServer struct {
+	listener net.Listener
+	authLine chan string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+
+	server := &fakeSMTPServer{listener: listener, authLine: make(chan string, 1)}
+	go server.serveOne(t)
+	return server
+}
+
+func (s *fakeSMTPServer) serveOne(t *testing.T) {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	writeLine := func(line string) {
+		fmt.Fprintf(conn, "%s\r\n", line)
+	}
+
+	writeLine("220 fake.smtp greeting")
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		switch {
+		case strings.HasPrefix(strings.ToUpper(line), "EHLO"):
+			writeLine("250-fake.smtp")
+			writeLine("250 AUTH XOAUTH2 PLAIN")
+		case strings.HasPrefix(strings.ToUpper(line), "AUTH XOAUTH2 "):
+			encoded := strings.TrimPrefix(line, "AUTH XOAUTH2 ")
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				writeLine("501 bad base64")
+				continue
+			}
+			s.authLine <- string(decoded)
+			writeLine("235 2.7.0 Authentication successful")
+		case strings.HasPrefix(strings.ToUpper(line), "AUTH PLAIN "):
+			encoded := strings.TrimPrefix(line, "AUTH PLAIN ")
+			decoded, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				writeLine("501 bad base64")
+				continue
+			}
+			s.authLine <- string(decoded)
+			writeLine("235 2.7.0 Authentication successful")
+		case strings.HasPrefix(strings.ToUpper(line), "QUIT"):
+			writeLine("221 bye")
+			return
+		default:
+			writeLine("500 unrecognized command")
+		}
+	}
+}
+
+func (s *fakeSMTPServer) addr() string {
+	return s.listener.Addr().String()
+}
+
+func (s *fakeSMTPServer) close() {
+	s.listener.Close()
+}
+
+// staticTokenSource is a [TokenSource] that always returns the same token, for tests.
+type staticTokenSource struct {
+	token *Token
+}
+
+func (s staticTokenSource) Token() (*Token, error) {
+	return s.token, nil
+}
+
+func TestXOAUTH2SASLExchange(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	defer server.close()
+
+	host, port, err := net.SplitHostPort(server.addr())
+	if err != nil {
+		t.Fatalf("failed to split server address: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		t.Fatalf("failed to dial fake server: %v", err)
+	}
+
+	client, err := smtp.NewClient(conn, host)
+	if err != nil {
+		t.Fatalf("failed to create SMTP client: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Hello("localhost"); err != nil {
+		t.Fatalf("EHLO failed: %v", err)
+	}
+
+	auth := NewXOAUTH2Auth("alice@example.com", staticTokenSource{token: &Token{AccessToken: "test-access-token"}})
+	if err := client.Auth(auth); err != nil {
+		t.Fatalf("AUTH XOAUTH2 failed: %v", err)
+	}
+
+	gotInitialResponse := <-server.authLine
+	wantInitialResponse := "user=alice@example.com\x01auth=Bearer test-access-token\x01\x01"
+	if gotInitialResponse != wantInitialResponse {
+		t.Fatalf("initial response = %q, want %q", gotInitialResponse, wantInitialResponse)
+	}
+
+	client.Quit()
+}
+
+// TestPlainAuthOverNonTLSNonLocalhostConnection exercises NewPlainAuth against a
+// server that's neither TLS nor named "localhost" - the combination the standard
+// library's smtp.PlainAuth refuses to run over (see plainAuth's doc comment). A
+// dedicated non-localhost server name proves this implementation doesn't inherit
+// that restriction, leaving FAROE_SMTP_ALLOW_INSECURE_AUTH as the only gate.
+func TestPlainAuthOverNonTLSNonLocalhostConnection(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	defer server.close()
+
+	host, port, err := net.SplitHostPort(server.addr())
+	if err != nil {
+		t.Fatalf("failed to split server address: %v", err)
+	}
+
+	conn, err := net.Dial("tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		t.Fatalf("failed to dial fake server: %v", err)
+	}
+
+	client, err := smtp.NewClient(conn, "mail.example.com")
+	if err != nil {
+		t.Fatalf("failed to create SMTP client: %v", err)
+	}
+	defer client.Close()
+
+	if err := client.Hello("localhost"); err != nil {
+		t.Fatalf("EHLO failed: %v", err)
+	}
+
+	auth := NewPlainAuth("alice", "s3cret")
+	if err := client.Auth(auth); err != nil {
+		t.Fatalf("AUTH PLAIN failed: %v", err)
+	}
+
+	gotInitialResponse := <-server.authLine
+	wantInitialResponse := "\x00alice\x00s3cret"
+	if gotInitialResponse != wantInitialResponse {
+		t.Fatalf("initial response = %q, want %q", gotInitialResponse, wantInitialResponse)
+	}
+
+	client.Quit()
+}
@@ -0,0 +1,1246 @@
+package tiauth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/tls"
+	"embed"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	mathrand "math/rand"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	neturl "net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+)
+
+type ipVersion int
+
+const (
+	ipv4 ipVersion = iota
+	ipv6
+	ipAny
+)
+
+func (v ipVersion) Network() string {
+	switch v {
+	case ipAny:
+		return "tcp"
+	case ipv4:
+		return "tcp4"
+	case ipv6:
+		return "tcp6"
+	default:
+		panic("invalid option!")
+	}
+}
+
+// smtpTLSMode selects how (or whether) smtpEmailClient wraps its connection in TLS.
+type smtpTLSMode int
+
+const (
+	// smtpTLSSTARTTLS dials plaintext, then upgrades via the STARTTLS command - the
+	// usual mode for port 587.
+	smtpTLSSTARTTLS smtpTLSMode = iota
+	// smtpTLSNone never enables TLS. Only for testing against a local/trusted
+	// server - credentials and message bodies travel in the clear.
+	smtpTLSNone
+	// smtpTLSImplicit wraps the raw net.Conn in tls.Client before handing it to
+	// smtp.NewClient, for servers that speak TLS from the first byte (port 465)
+	// rather than negotiating it via STARTTLS.
+	smtpTLSImplicit
+)
+
+// parseSMTPTLSMode parses the FAROE_SMTP_TLS_MODE config value (case-insensitive)
+// into an [smtpTLSMode]. An empty raw string is not valid input; callers decide the
+// unset default themselves.
+func parseSMTPTLSMode(raw string) (smtpTLSMode, error) {
+	switch strings.ToLower(raw) {
+	case "starttls":
+		return smtpTLSSTARTTLS, nil
+	case "none":
+		return smtpTLSNone, nil
+	case "implicit":
+		return smtpTLSImplicit, nil
+	default:
+		return 0, fmt.Errorf("unknown TLS mode %q (must be \"starttls\", \"none\", or \"implicit\")", raw)
+	}
+}
+
+// smtpConfig configures the SMTP transport only. The sender identity (name/address)
+// is no longer part of it - it's passed into EmailClient.Send by actionsEmailSender,
+// the same way for every backend.
+type smtpConfig struct {
+	// Used for the Message-ID
+	domain     string
+	serverHost string
+	serverPort string
+	ipVersion  ipVersion
+	// Can be nil, in which case no authentication is performed
+	auth    smtp.Auth
+	tlsMode smtpTLSMode
+	// Disable keepAlive, if unset defaults to false (keepAlive enabled)
+	disableKeepAlive bool
+
+	// connectionTimeout bounds the initial TCP handshake (net.Dialer.Timeout).
+	// Zero means no timeout (net.Dialer's default).
+	connectionTimeout time.Duration
+	// tcpKeepalivePeriod is passed as net.Dialer.KeepAlive: how often the kernel
+	// sends TCP keepalive probes once the connection is idle. Zero uses the Go
+	// runtime's default (15s); negative disables OS-level keepalive entirely.
+	tcpKeepalivePeriod time.Duration
+	// tcpKeepaliveProbes and tcpKeepaliveInterval set TCP_KEEPCNT/TCP_KEEPINTVL on
+	// the connected socket (Linux only - see smtp_keepalive_linux.go), giving finer
+	// control than net.Dialer.KeepAlive alone over how quickly a dead connection
+	// through an idle-killing NAT/load balancer is detected. Zero leaves the OS
+	// default for that setting untouched.
+	tcpKeepaliveProbes   int
+	tcpKeepaliveInterval time.Duration
+
+	// maxAttempts bounds how many times sendOne tries delivery to one recipient
+	// before giving up. Zero defaults to 3 (the hardcoded retry count this replaces).
+	maxAttempts int
+	// initialBackoff is the delay before the second attempt; each further retry
+	// doubles it, capped at maxBackoff. Zero disables the delay, retrying
+	// immediately (the previous behavior). jitter adds up to a random extra
+	// duration on top of each computed delay, so many recipients failing against
+	// the same downstream server don't all retry in lockstep.
+	initialBackoff time.Duration
+	maxBackoff     time.Duration
+	jitter         time.Duration
+
+	// rateLimitPerDomain and rateLimitBurst configure a token-bucket limiter keyed
+	// on the recipient's email domain (see rate_limit.go), so a burst of sends to
+	// one domain - e.g. a flood of password resets - can't trip that provider's
+	// per-domain rate cap while other domains keep flowing. Zero rateLimitPerDomain
+	// disables rate limiting entirely.
+	rateLimitPerDomain float64
+	rateLimitBurst     float64
+
+	// onError, if set, is called by the owner goroutine whenever a keep-alive probe
+	// or reconnect attempt fails - the same error Healthy/LastError would report, but
+	// pushed rather than polled, for a caller that wants to log or alert on it
+	// immediately instead of waiting to be asked. Called synchronously from the
+	// owner goroutine, so it must not block or call back into the client.
+	onError func(error)
+
+	// dkim, if set, DKIM-signs every outgoing message (see dkim.go). Nil sends
+	// unsigned, as before this field existed.
+	dkim *dkimSigner
+}
+
+// TemplateRenderer renders a named template with the given data. Implementations
+// decide where templates come from: [dirTemplateRenderer] re-reads a directory on
+// every render so operators can iterate without restarting, while
+// [embeddedTemplateRenderer] serves the binary's built-in defaults.
+type TemplateRenderer interface {
+	// Render returns the empty string, nil if no template with that name exists.
+	Render(name string, data any) (string, error)
+}
+
+// dirTemplateRenderer loads .txt/.html/.md templates from a directory on every call,
+// so edits on disk take effect without restarting the process (dev mode).
+type dirTemplateRenderer struct {
+	dir string
+}
+
+// NewDirTemplateRenderer returns a [TemplateRenderer] that reloads templates from dir
+// on every Render call.
+func NewDirTemplateRenderer(dir string) TemplateRenderer {
+	return &dirTemplateRenderer{dir: dir}
+}
+
+func (r *dirTemplateRenderer) Render(name string, data any) (string, error) {
+	tmpl, err := loadEmailTemplates(r.dir)
+	if err != nil {
+		return "", err
+	}
+	if tmpl == nil {
+		return "", nil
+	}
+	return executeTemplate(tmpl, name, data)
+}
+
+// embeddedTemplateRenderer serves templates baked into the binary via go:embed
+// (prod mode): no filesystem access is needed at runtime.
+type embeddedTemplateRenderer struct {
+	tmpl *template.Template
+}
+
+// NewEmbeddedTemplateRenderer parses every .txt/.html/.md file under root in fsys once.
+func NewEmbeddedTemplateRenderer(fsys embed.FS, root string) (TemplateRenderer, error) {
+	tmpl := template.New("")
+
+	entries, err := fsys.ReadDir(root)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read embedded templates: %v", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		ext := filepath.Ext(entry.Name())
+		if ext != ".txt" && ext != ".html" && ext != ".md" {
+			continue
+		}
+
+		content, err := fsys.ReadFile(filepath.Join(root, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("failed to read embedded template %s: %v", entry.Name(), err)
+		}
+
+		if _, err := tmpl.New(entry.Name()).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("failed to parse embedded template %s: %v", entry.Name(), err)
+		}
+	}
+
+	return &embeddedTemplateRenderer{tmpl: tmpl}, nil
+}
+
+func (r *embeddedTemplateRenderer) Render(name string, data any) (string, error) {
+	return executeTemplate(r.tmpl, name, data)
+}
+
+func executeTemplate(tmpl *template.Template, name string, data any) (string, error) {
+	lookup := tmpl.Lookup(name)
+	if lookup == nil {
+		return "", nil
+	}
+
+	var buf bytes.Buffer
+	if err := lookup.Execute(&buf, data); err != nil {
+		return "", fmt.Errorf("failed to render template %s: %v", name, err)
+	}
+
+	return buf.String(), nil
+}
+
+func loadEmailTemplates(templatesPath string) (*template.Template, error) {
+	if templatesPath == "" {
+		return nil, nil
+	}
+
+	// Check if directory exists
+	if _, err := os.Stat(templatesPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("templates directory does not exist: %s", templatesPath)
+	}
+
+	tmpl := template.New("")
+
+	// Find all .txt, .html, and .md files in the templates directory
+	pattern := filepath.Join(templatesPath, "*")
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list template files: %v", err)
+	}
+
+	for _, file := range files {
+		ext := filepath.Ext(file)
+		if ext == ".txt" || ext == ".html" || ext == ".md" {
+			content, err := os.ReadFile(file)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read template file %s: %v", file, err)
+			}
+
+			// Use the base name (without extension) as the template name
+			baseName := filepath.Base(file)
+			_, err = tmpl.New(baseName).Parse(string(content))
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse template %s: %v", file, err)
+			}
+		}
+	}
+
+	return tmpl, nil
+}
+
+// smtpEmailClient is the [EmailClient] implementation that speaks SMTP directly over
+// a persistent, reconnecting *smtp.Client. It also implements [emailClientLifecycle]:
+// Start dials and authenticates the initial connection and (unless disabled) launches
+// a background NOOP keep-alive loop; Close stops that loop and sends QUIT.
+// smtpSendRequest is one Send/SendCtx call's worth of work handed to the owner
+// goroutine over smtpEmailClient.sendChan; result carries back whatever sendOwned
+// returns.
+type smtpSendRequest struct {
+	ctx           context.Context
+	fromName      string
+	fromAddr      string
+	receiverEmail string
+	msg           *Message
+	result        chan error
+}
+
+// smtpEmailClient talks to one SMTP server over a single persistent connection. That
+// connection (c.client) is only ever touched by the run goroutine started in Start -
+// Send/SendCtx/ProbeNow hand their work to it over sendChan/probeChan instead of
+// taking a mutex around the connection themselves, so there's no way for a send to
+// observe the connection mid-reconnect or to deadlock holding a lock across a retry's
+// backoff sleep.
+type smtpEmailClient struct {
+	config  *smtpConfig
+	limiter *domainRateLimiter
+
+	// client is owned exclusively by run; nothing else may read or write it.
+	client *smtp.Client
+
+	sendChan    chan *smtpSendRequest
+	probeChan   chan chan error
+	stopChan    chan struct{}
+	doneChan    chan struct{}
+	quitErrChan chan error
+
+	// startMu/started/closed guard one-time setup and teardown only (allocating the
+	// channels above, launching run, and making Close idempotent) - never the
+	// connection itself, so it's never held across a network call.
+	startMu sync.Mutex
+	started bool
+	closed  bool
+
+	healthMu sync.RWMutex
+	healthy  bool
+	lastErr  error
+}
+
+func newSMTPEmailClient(config *smtpConfig) *smtpEmailClient {
+	c := &smtpEmailClient{config: config}
+	if config.rateLimitPerDomain > 0 {
+		c.limiter = newDomainRateLimiter(config.rateLimitPerDomain, config.rateLimitBurst)
+	}
+	return c
+}
+
+func createConnectedSmtpClient(config *smtpConfig) (*smtp.Client, error) {
+	serverAddr := config.serverHost + ":" + config.serverPort
+	// We don't use SMTP dial because then the local name is set to "localhost", which can lead to
+	// issues when using e.g. IP authentication
+	dialer := net.Dialer{
+		Timeout:   config.connectionTimeout,
+		KeepAlive: config.tcpKeepalivePeriod,
+	}
+	conn, err := dialer.Dial(config.ipVersion.Network(), serverAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to server at %s: %v", serverAddr, err)
+	}
+
+	if tcpConn, ok := conn.(*net.TCPConn); ok && (config.tcpKeepaliveProbes > 0 || config.tcpKeepaliveInterval > 0) {
+		if err := tuneTCPKeepalive(tcpConn, config.tcpKeepaliveProbes, config.tcpKeepaliveInterval); err != nil {
+			log.Printf("failed to tune TCP keepalive for SMTP connection: %v", err)
+		}
+	}
+
+	// Implicit TLS servers (typically port 465) speak TLS from the first byte, so the
+	// handshake happens here, before smtp.NewClient ever sees the connection. STARTTLS
+	// servers negotiate it in-band below instead, once smtp.NewClient can send the
+	// command.
+	if config.tlsMode == smtpTLSImplicit {
+		conn = tls.Client(conn, &tls.Config{ServerName: config.serverHost})
+	}
+
+	client, err := smtp.NewClient(conn, config.serverHost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to establish SMTP client: %v", err)
+	}
+
+	// We set the localName based on the actual connection address, which is done using `client.Hello`
+	localAddr := conn.LocalAddr().String()
+	localName, _, _ := net.SplitHostPort(localAddr)
+	err = client.Hello(localName)
+	if err != nil {
+		return nil, fmt.Errorf("error sending EHLO: %v", err)
+	}
+
+	switch config.tlsMode {
+	case smtpTLSSTARTTLS:
+		tlsConfig := &tls.Config{
+			ServerName: config.serverHost,
+		}
+		if err = client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to start TLS: %v", err)
+		}
+	case smtpTLSImplicit:
+		// Already wrapped in tls.Client above.
+	case smtpTLSNone:
+		log.Println("[DANGER] TLS not enabled, messages are not secured and can be read when intercepted!")
+	}
+
+	if config.auth != nil {
+		if err = client.Auth(config.auth); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("failed to authenticate: %v", err)
+		}
+	}
+	return client, nil
+}
+
+func generateMessageID(senderEmail, receiverEmail, body string, sendTime string, domain string) string {
+	input := fmt.Sprintf("%s|%s|%s|%s", senderEmail, receiverEmail, body, sendTime)
+	hash := sha256.Sum256([]byte(input))
+
+	// Using just the first 32 is fine, this is not used for any security purposes
+	hashStr := fmt.Sprintf("%x", hash)[:32]
+
+	return fmt.Sprintf("<%s@%s>", hashStr, domain)
+}
+
+// generateBoundary creates a random boundary for multipart MIME messages
+func generateBoundary() string {
+	var buf [16]byte
+	rand.Read(buf[:])
+	return "boundary_" + hex.EncodeToString(buf[:])
+}
+
+// Send implements [EmailClient] by delivering msg to each address in to over the
+// persistent SMTP connection. A Markdown body has no native SMTP representation, so
+// it is ignored here - callers that want Markdown rendered should populate msg.HTML
+// themselves.
+func (c *smtpEmailClient) Send(fromName, fromAddr string, msg *Message, to ...string) error {
+	return c.SendCtx(context.Background(), fromName, fromAddr, msg, to...)
+}
+
+// SendCtx implements [emailClientContextSender]: same as Send, but a canceled ctx
+// cuts short a rate-limit wait, a retry's backoff sleep, or the wait for the owner
+// goroutine to pick up the request, instead of blocking through it.
+func (c *smtpEmailClient) SendCtx(ctx context.Context, fromName, fromAddr string, msg *Message, to ...string) error {
+	if err := c.ensureStarted(); err != nil {
+		return err
+	}
+	for _, receiverEmail := range to {
+		if c.limiter != nil {
+			if err := c.limiter.Wait(ctx, receiverEmail); err != nil {
+				return fmt.Errorf("rate limit wait for %s: %w", receiverEmail, err)
+			}
+		}
+		if err := c.sendToOwner(ctx, fromName, fromAddr, receiverEmail, msg); err != nil {
+			return fmt.Errorf("sending to %s: %w", receiverEmail, err)
+		}
+	}
+	return nil
+}
+
+// sendToOwner hands one recipient's send off to the run goroutine and waits for its
+// result, letting ctx cancel either the handoff or the wait.
+func (c *smtpEmailClient) sendToOwner(ctx context.Context, fromName, fromAddr, receiverEmail string, msg *Message) error {
+	req := &smtpSendRequest{
+		ctx: ctx, fromName: fromName, fromAddr: fromAddr, receiverEmail: receiverEmail, msg: msg,
+		result: make(chan error, 1),
+	}
+	select {
+	case c.sendChan <- req:
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-c.doneChan:
+		return errors.New("smtp client is closed")
+	}
+	select {
+	case err := <-req.result:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// ensureStarted lazily starts the owner goroutine with a conservative default
+// keep-alive interval for callers (tests, or a bare *smtpEmailClient used outside
+// actionsEmailSender) that send without calling Start themselves. A no-op once
+// started.
+func (c *smtpEmailClient) ensureStarted() error {
+	return c.Start(5 * time.Minute)
+}
+
+// backoffDuration returns the delay before retry attempt number attempt (0-indexed,
+// so attempt 0 is the wait before the second overall try). Returns 0 if backoff is
+// disabled (initialBackoff unset).
+func (c *smtpEmailClient) backoffDuration(attempt int) time.Duration {
+	if c.config.initialBackoff <= 0 {
+		return 0
+	}
+	d := c.config.initialBackoff
+	for range attempt {
+		d *= 2
+		if c.config.maxBackoff > 0 && d > c.config.maxBackoff {
+			d = c.config.maxBackoff
+			break
+		}
+	}
+	if c.config.jitter > 0 {
+		d += time.Duration(mathrand.Int63n(int64(c.config.jitter)))
+	}
+	return d
+}
+
+// smtpErrorPermanent reports whether err is a 5xx SMTP reply, which faroe/the
+// operator needs to fix (bad address, policy rejection, ...) rather than something
+// retrying will resolve. A 4xx reply or a non-protocol error (e.g. a dropped
+// connection) is treated as retryable.
+func smtpErrorPermanent(err error) bool {
+	var protoErr *textproto.Error
+	if errors.As(err, &protoErr) {
+		return protoErr.Code/100 == 5
+	}
+	return false
+}
+
+// sendOwned delivers one message to one recipient over c.client, retrying per
+// config's attempt/backoff policy. Must only be called from run, the owner
+// goroutine - it's the only thing that ever touches c.client, so unlike the mutex
+// this function used to hold, there's no way for it to leave the connection in a
+// state another goroutine observes mid-reconnect.
+func (c *smtpEmailClient) sendOwned(ctx context.Context, fromName, fromAddr, receiverEmail string, msg *Message) error {
+	var fromHeader string
+	if fromName != "" {
+		fromHeader = fmt.Sprintf("%s <%s>", fromName, fromAddr)
+	} else {
+		fromHeader = fromAddr
+	}
+
+	// receiverEmail may carry a display name ("Name <addr>", built by
+	// actionsEmailSender) for the To: header, but RCPT TO needs the bare envelope
+	// address - parse it back out rather than requiring a separate parameter.
+	receiverAddr := receiverEmail
+	if parsed, err := mail.ParseAddress(receiverEmail); err == nil {
+		receiverAddr = parsed.Address
+	}
+
+	date := time.Now().Format(time.RFC1123Z)
+	messageId := generateMessageID(fromAddr, receiverAddr, msg.Text, date, c.config.domain)
+
+	var headers []string
+	var bodyContent string
+
+	if msg.HTML != "" {
+		// Create multipart/alternative message with both text and HTML
+		boundary := generateBoundary()
+
+		headers = []string{
+			fmt.Sprintf("From: %s", fromHeader),
+			fmt.Sprintf("To: %s", receiverEmail),
+			fmt.Sprintf("Subject: %s", msg.Subject),
+			fmt.Sprintf("Date: %s", date),
+			fmt.Sprintf("Message-ID: %s", messageId),
+			"MIME-Version: 1.0",
+			fmt.Sprintf("Content-Type: multipart/alternative; boundary=\"%s\"", boundary),
+		}
+
+		var buf bytes.Buffer
+
+		// Create multipart writer
+		writer := multipart.NewWriter(&buf)
+		writer.SetBoundary(boundary)
+
+		// Add plain text part
+		textHeader := textproto.MIMEHeader{}
+		textHeader.Set("Content-Type", "text/plain; charset=UTF-8")
+		textPart, _ := writer.CreatePart(textHeader)
+		textPart.Write([]byte(msg.Text))
+
+		// Add HTML part
+		htmlHeader := textproto.MIMEHeader{}
+		htmlHeader.Set("Content-Type", "text/html; charset=UTF-8")
+		htmlPart, _ := writer.CreatePart(htmlHeader)
+		htmlPart.Write([]byte(msg.HTML))
+
+		writer.Close()
+		bodyContent = buf.String()
+	} else {
+		// Simple plain text message
+		headers = []string{
+			fmt.Sprintf("From: %s", fromHeader),
+			fmt.Sprintf("To: %s", receiverEmail),
+			fmt.Sprintf("Subject: %s", msg.Subject),
+			fmt.Sprintf("Date: %s", date),
+			fmt.Sprintf("Message-ID: %s", messageId),
+			"MIME-Version: 1.0",
+			"Content-Type: text/plain; charset=UTF-8",
+		}
+
+		bodyContent = msg.Text
+	}
+
+	headers = append(headers, msg.ExtraHeaders...)
+
+	if c.config.dkim != nil {
+		if sig, err := c.config.dkim.sign(headers, bodyContent); err == nil {
+			headers = append([]string{"DKIM-Signature: " + sig}, headers...)
+		} else {
+			log.Printf("DKIM signing failed, sending %s to %s unsigned: %v", messageId, receiverAddr, err)
+		}
+	}
+
+	message := strings.Join(headers, "\r\n") + "\r\n\r\n" + bodyContent
+
+	maxAttempts := c.config.maxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
+	}
+
+	var mailErr error = nil
+	for attempt := range maxAttempts {
+		if mailErr != nil {
+			if smtpErrorPermanent(mailErr) {
+				break
+			}
+
+			if backoff := c.backoffDuration(attempt - 1); backoff > 0 {
+				timer := time.NewTimer(backoff)
+				select {
+				case <-timer.C:
+				case <-ctx.Done():
+					timer.Stop()
+					return ctx.Err()
+				}
+			}
+
+			newClient, err := createConnectedSmtpClient(c.config)
+			if err != nil {
+				c.setHealth(err)
+				return err
+			}
+			// The old client is presumed broken (that's why we're here) - best-effort
+			// close it so a flaky connection doesn't leak a socket per retry.
+			c.client.Close()
+			c.client = newClient
+		}
+
+		if c.client == nil {
+			newClient, err := createConnectedSmtpClient(c.config)
+			if err != nil {
+				c.setHealth(err)
+				return err
+			}
+			c.client = newClient
+		}
+
+		err := c.client.Mail(fromAddr)
+		if err != nil {
+			mailErr = fmt.Errorf("failed to set sender: %w", err)
+			continue
+		}
+
+		if err = c.client.Rcpt(receiverAddr); err != nil {
+			mailErr = fmt.Errorf("failed to set recipient: %w", err)
+			continue
+		}
+
+		writer, err := c.client.Data()
+		if err != nil {
+			mailErr = fmt.Errorf("failed to get data writer: %w", err)
+			continue
+		}
+
+		_, err = writer.Write([]byte(message))
+		if err != nil {
+			mailErr = fmt.Errorf("failed to write message: %w", err)
+			continue
+		}
+
+		err = writer.Close()
+		if err != nil {
+			mailErr = fmt.Errorf("failed to close writer: %w", err)
+			continue
+		}
+
+		// If we reach here everything is successful, so reset any previous errors and break loop
+		mailErr = nil
+		c.setHealth(nil)
+		break
+	}
+
+	return mailErr
+}
+
+// Close implements [emailClientLifecycle]: stops the owner goroutine (which quits
+// the underlying connection itself, since it's the only thing allowed to touch it)
+// and waits for it to finish.
+func (c *smtpEmailClient) Close() error {
+	c.startMu.Lock()
+	if !c.started || c.closed {
+		c.startMu.Unlock()
+		return nil
+	}
+	c.closed = true
+	c.startMu.Unlock()
+
+	close(c.stopChan)
+	return <-c.quitErrChan
+}
+
+// setHealth records the outcome of the most recent connection attempt or probe, for
+// Healthy/LastError to report back synchronously.
+func (c *smtpEmailClient) setHealth(err error) {
+	c.healthMu.Lock()
+	c.healthy = err == nil
+	c.lastErr = err
+	c.healthMu.Unlock()
+
+	if err != nil && c.config.onError != nil {
+		c.config.onError(err)
+	}
+}
+
+// Healthy reports whether the connection itself is currently usable, as of the most
+// recent connect, keep-alive probe, or ProbeNow call. False before the first one
+// completes. It does not reflect a per-message delivery failure (e.g. a permanent
+// rejection of one recipient) that leaves the connection itself fine for the next
+// message - see LastError for that distinction.
+func (c *smtpEmailClient) Healthy() bool {
+	c.healthMu.RLock()
+	defer c.healthMu.RUnlock()
+	return c.healthy
+}
+
+// LastError returns the error from the most recent failed connection attempt or
+// probe, or nil if the last one succeeded (or none has run yet).
+func (c *smtpEmailClient) LastError() error {
+	c.healthMu.RLock()
+	defer c.healthMu.RUnlock()
+	return c.lastErr
+}
+
+// ProbeNow synchronously verifies the SMTP connection - a NOOP, reconnecting once if
+// that fails - without waiting for the next keep-alive tick. Intended for a /healthz
+// handler that wants the connection's current state on demand rather than whatever
+// Healthy last recorded.
+func (c *smtpEmailClient) ProbeNow() error {
+	if err := c.ensureStarted(); err != nil {
+		return err
+	}
+	resp := make(chan error, 1)
+	select {
+	case c.probeChan <- resp:
+	case <-c.doneChan:
+		return errors.New("smtp client is closed")
+	}
+	return <-resp
+}
+
+// Start implements [emailClientLifecycle]: establishes the initial connection and
+// launches run, the owner goroutine that from here on is the only thing allowed to
+// touch c.client. Idempotent - a second call is a no-op as long as the first
+// succeeded.
+func (c *smtpEmailClient) Start(interval time.Duration) error {
+	c.startMu.Lock()
+	defer c.startMu.Unlock()
+	if c.started {
+		return nil
+	}
+
+	c.sendChan = make(chan *smtpSendRequest)
+	c.probeChan = make(chan chan error)
+	c.stopChan = make(chan struct{})
+	c.doneChan = make(chan struct{})
+	c.quitErrChan = make(chan error, 1)
+
+	newClient, err := createConnectedSmtpClient(c.config)
+	if err != nil {
+		c.setHealth(err)
+		return fmt.Errorf("could not start emailSender: %v", err)
+	}
+	c.client = newClient
+	c.setHealth(nil)
+	c.started = true
+
+	go c.run(interval)
+	return nil
+}
+
+// run is the owner goroutine: it's the only code that ever reads or writes c.client,
+// so a send can never observe it mid-reconnect and a keep-alive failure can never
+// leave anything locked. It serves send requests and on-demand probes as they
+// arrive, and (unless disableKeepAlive) probes proactively on a ticker.
+func (c *smtpEmailClient) run(interval time.Duration) {
+	var tickChan <-chan time.Time
+	if !c.config.disableKeepAlive && interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tickChan = ticker.C
+	}
+
+	for {
+		select {
+		case req := <-c.sendChan:
+			req.result <- c.sendOwned(req.ctx, req.fromName, req.fromAddr, req.receiverEmail, req.msg)
+		case resp := <-c.probeChan:
+			resp <- c.probeOwned()
+		case <-tickChan:
+			// Failure is reported via setHealth (Healthy/LastError/onError), not here -
+			// logging it twice would just be noise for a caller that wired onError up.
+			c.probeOwned()
+		case <-c.stopChan:
+			var quitErr error
+			if c.client != nil {
+				quitErr = c.client.Quit()
+			}
+			c.quitErrChan <- quitErr
+			close(c.doneChan)
+			return
+		}
+	}
+}
+
+// probeOwned verifies the connection with a NOOP, reconnecting once if that fails.
+// Must only be called from run.
+func (c *smtpEmailClient) probeOwned() error {
+	if c.client != nil {
+		if err := c.client.Noop(); err == nil {
+			c.setHealth(nil)
+			return nil
+		}
+		// The NOOP failed, so the connection is presumed dead - best-effort close it
+		// before reconnecting so a repeatedly-failing probe doesn't leak a socket.
+		c.client.Close()
+	}
+
+	newClient, err := createConnectedSmtpClient(c.config)
+	if err != nil {
+		probeErr := fmt.Errorf("SMTP connection probe failed, reconnect also failed: %w", err)
+		c.setHealth(probeErr)
+		return probeErr
+	}
+	c.client = newClient
+	c.setHealth(nil)
+	return nil
+}
+
+// actionsEmailSender implements faroe.EmailSenderInterface's six action methods on
+// top of any [EmailClient]: it renders an optional template (falling back to a
+// hardcoded message when none is configured) into a [Message] and hands it to client,
+// so the per-action logic and wording stay the same regardless of which provider
+// (SMTP, Mailgun, SES, webhook) actually delivers it.
+type actionsEmailSender struct {
+	senderName       string
+	senderEmail      string
+	client           EmailClient
+	templates        TemplateRenderer
+	tokenBroadcaster *TokenBroadcaster
+
+	// unsubscribeURLBuilder, if set, returns the preference-center URL to offer as
+	// the RFC 8058 one-click unsubscribe target for notificationType notifications
+	// sent to emailAddress - see unsubscribeHeaders. Nil omits List-Unsubscribe
+	// entirely, as before this field existed.
+	unsubscribeURLBuilder func(emailAddress, notificationType string) string
+}
+
+// SendEmail sends a plain-text probe/test message - used by the "email test" shell
+// command, not by faroe's action invocations (those go through SendEmailWithHTML).
+func (emailSender *actionsEmailSender) SendEmail(receiverEmail string, subject string, body string) error {
+	return emailSender.SendEmailWithHTML(receiverEmail, subject, body, "")
+}
+
+// SendEmailWithHTML sends an email with both plain text and HTML parts through the
+// configured client.
+func (emailSender *actionsEmailSender) SendEmailWithHTML(receiverEmail string, subject string, body string, htmlBody string) error {
+	return emailSender.sendToRecipient("", receiverEmail, subject, body, htmlBody)
+}
+
+// SendEmailWithHTMLCtx is SendEmailWithHTML with a context a caller can cancel to
+// give up on an in-flight send - e.g. during graceful shutdown, so a process doesn't
+// block on a client's multi-attempt retry backoff. Clients that don't implement
+// [emailClientContextSender] (Mailgun, SES, webhook; all single-shot HTTP calls) fall
+// back to the plain Send and ignore ctx.
+func (emailSender *actionsEmailSender) SendEmailWithHTMLCtx(ctx context.Context, receiverEmail string, subject string, body string, htmlBody string) error {
+	return emailSender.sendToRecipientCtx(ctx, "", receiverEmail, subject, body, htmlBody)
+}
+
+// sendToRecipient is SendEmailWithHTML plus a recipient display name and optional
+// raw extra header lines (e.g. List-Unsubscribe, from unsubscribeHeaders), used by
+// the per-action Send* methods below so the "To:" header/field carries the user's
+// DisplayName the same way it did before this package grew multiple EmailClients.
+func (emailSender *actionsEmailSender) sendToRecipient(displayName, receiverEmail, subject, body, htmlBody string, extraHeaders ...string) error {
+	return emailSender.sendToRecipientCtx(context.Background(), displayName, receiverEmail, subject, body, htmlBody, extraHeaders...)
+}
+
+func (emailSender *actionsEmailSender) sendToRecipientCtx(ctx context.Context, displayName, receiverEmail, subject, body, htmlBody string, extraHeaders ...string) error {
+	msg := &Message{Subject: subject, Text: body, HTML: htmlBody, ExtraHeaders: extraHeaders}
+	to := receiverEmail
+	if displayName != "" {
+		to = (&mail.Address{Name: displayName, Address: receiverEmail}).String()
+	}
+	if ctxSender, ok := emailSender.client.(emailClientContextSender); ok {
+		return ctxSender.SendCtx(ctx, emailSender.senderName, emailSender.senderEmail, msg, to)
+	}
+	return emailSender.client.Send(emailSender.senderName, emailSender.senderEmail, msg, to)
+}
+
+// Close releases the underlying client's connection, if it holds one.
+func (emailSender *actionsEmailSender) Close() error {
+	if lifecycle, ok := emailSender.client.(emailClientLifecycle); ok {
+		return lifecycle.Close()
+	}
+	return nil
+}
+
+// Start brings up the underlying client's connection, if it needs one. A no-op for
+// stateless per-call clients (Mailgun, SES, webhook).
+func (emailSender *actionsEmailSender) Start(interval time.Duration) error {
+	if lifecycle, ok := emailSender.client.(emailClientLifecycle); ok {
+		return lifecycle.Start(interval)
+	}
+	return nil
+}
+
+// ErrChan returns the channel the underlying client reports a fatal background
+// failure on, or nil for a client that never reports one asynchronously (which now
+// includes smtpEmailClient - its connection trouble is surfaced synchronously via
+// Healthy/LastError/ProbeNow instead, since the owner goroutine retries reconnecting
+// rather than giving up).
+func (emailSender *actionsEmailSender) ErrChan() <-chan error {
+	if c, ok := emailSender.client.(interface{ ErrChan() <-chan error }); ok {
+		return c.ErrChan()
+	}
+	return nil
+}
+
+// unsubscribeHeaders builds the List-Unsubscribe/List-Unsubscribe-Post header pair
+// RFC 8058 one-click unsubscribe requires, for an account-activity notification of
+// the given notificationType (e.g. "signin", "password_updated", "email_updated")
+// sent to emailAddress. Returns nil if no unsubscribeURLBuilder is configured -
+// verification codes and password resets never call this, only the notifications
+// below that a user might reasonably want to mute.
+func (emailSender *actionsEmailSender) unsubscribeHeaders(emailAddress, notificationType string) []string {
+	if emailSender.unsubscribeURLBuilder == nil {
+		return nil
+	}
+	url := emailSender.unsubscribeURLBuilder(emailAddress, notificationType)
+	if url == "" {
+		return nil
+	}
+	return []string{
+		fmt.Sprintf("List-Unsubscribe: <mailto:%s>, <%s>", emailSender.senderEmail, url),
+		"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+	}
+}
+
+// buildUnsubscribeURLBuilder turns a Config.SMTPUnsubscribeURLTemplate string (with
+// "{email}"/"{type}" placeholders) into the closure actionsEmailSender.
+// unsubscribeURLBuilder expects. Returns nil for an empty template, so callers can
+// always assign the result straight into the field.
+func buildUnsubscribeURLBuilder(template string) func(emailAddress, notificationType string) string {
+	if template == "" {
+		return nil
+	}
+	return func(emailAddress, notificationType string) string {
+		url := strings.ReplaceAll(template, "{email}", neturl.QueryEscape(emailAddress))
+		url = strings.ReplaceAll(url, "{type}", notificationType)
+		return url
+	}
+}
+
+func makeGreeting(displayName string) string {
+	if displayName != "" {
+		return fmt.Sprintf("Dear %s,", displayName)
+	}
+	return "Hello,"
+}
+
+// renderTemplate renders a template with the given data, returns empty string if template doesn't exist
+func (emailSender *actionsEmailSender) renderTemplate(templateName string, data any) (string, error) {
+	if emailSender.templates == nil {
+		return "", nil
+	}
+
+	return emailSender.templates.Render(templateName, data)
+}
+
+// renderBodies resolves the text and HTML bodies for the "<baseName>.txt"/
+// "<baseName>.html"/"<baseName>.md" template family. An explicit .txt/.html pair
+// takes precedence; whichever of the two is missing falls back to a single .md
+// source rendered to HTML (via [renderMarkdownToHTML]) and to plain text (via
+// [stripMarkdownSyntax]) - so an operator only has to maintain one file per action
+// instead of two. Bodies that come back empty here fall through to the caller's own
+// hardcoded message.
+//
+// Callers that pass markdown-sensitive data (e.g. escapeMarkdownMetachars'd
+// DisplayName/Greeting fields) rely on the final unescapeMarkdownMetachars calls
+// below to restore the original characters, whichever path produced the body: a
+// plain .txt/.html pair never goes through the markdown renderer at all, and even
+// the .md path only strips markdown *syntax*, not the escaped stand-ins, so this is
+// the one place that always runs for both.
+func (emailSender *actionsEmailSender) renderBodies(baseName string, data any) (textBody, htmlBody string, err error) {
+	textBody, err = emailSender.renderTemplate(baseName+".txt", data)
+	if err != nil {
+		return "", "", err
+	}
+	htmlBody, err = emailSender.renderTemplate(baseName+".html", data)
+	if err != nil {
+		return "", "", err
+	}
+	if textBody != "" && htmlBody != "" {
+		return unescapeMarkdownMetachars(textBody), unescapeMarkdownMetachars(htmlBody), nil
+	}
+
+	mdBody, err := emailSender.renderTemplate(baseName+".md", data)
+	if err != nil {
+		return "", "", err
+	}
+	if mdBody == "" {
+		return unescapeMarkdownMetachars(textBody), unescapeMarkdownMetachars(htmlBody), nil
+	}
+
+	if textBody == "" {
+		textBody = stripMarkdownSyntax(mdBody)
+	}
+	if htmlBody == "" {
+		htmlBody = renderMarkdownToHTML(mdBody)
+	}
+
+	return unescapeMarkdownMetachars(textBody), unescapeMarkdownMetachars(htmlBody), nil
+}
+
+func (emailSender *actionsEmailSender) SendSignupEmailAddressVerificationCode(emailAddress string, emailAddressVerificationCode string) error {
+	// Broadcast token for testing/automation
+	if emailSender.tokenBroadcaster != nil {
+		emailSender.tokenBroadcaster.BroadcastSignupVerification(emailAddress, emailAddressVerificationCode)
+	}
+
+	subject := "Signup verification code"
+
+	data := map[string]any{
+		"EmailAddress":     escapeMarkdownMetachars(emailAddress),
+		"VerificationCode": emailAddressVerificationCode,
+	}
+
+	// Try to render templates (.txt/.html override, else a single .md source)
+	textBody, htmlBody, err := emailSender.renderBodies("signup_verification", data)
+	if err != nil {
+		return err
+	}
+
+	// Fallback to hardcoded message if no templates found
+	if textBody == "" {
+		textBody = fmt.Sprintf("Your email address verification code is %s.", emailAddressVerificationCode)
+	}
+
+	return emailSender.SendEmailWithHTML(emailAddress, subject, textBody, htmlBody)
+}
+
+func (emailSender *actionsEmailSender) SendUserEmailAddressUpdateEmailVerificationCode(emailAddress string, displayName string, emailAddressVerificationCode string) error {
+	// Broadcast token for testing/automation
+	if emailSender.tokenBroadcaster != nil {
+		emailSender.tokenBroadcaster.BroadcastEmailUpdateVerification(emailAddress, emailAddressVerificationCode)
+	}
+
+	subject := "Email update verification code"
+
+	// mdDisplayName feeds the .md template/greeting only - escapeMarkdownMetachars
+	// keeps a crafted display name like "[x](javascript:alert(1))" from being parsed
+	// as a live link by renderMarkdownToHTML. sendToRecipient below still gets the
+	// unescaped displayName, since mail.Address already escapes it correctly for the
+	// "To:" header and doesn't go through the markdown renderer.
+	mdDisplayName := escapeMarkdownMetachars(displayName)
+
+	data := map[string]any{
+		"EmailAddress":     escapeMarkdownMetachars(emailAddress),
+		"DisplayName":      mdDisplayName,
+		"VerificationCode": emailAddressVerificationCode,
+		"Greeting":         makeGreeting(mdDisplayName),
+	}
+
+	// Try to render templates (.txt/.html override, else a single .md source)
+	textBody, htmlBody, err := emailSender.renderBodies("email_update_verification", data)
+	if err != nil {
+		return err
+	}
+
+	// Fallback to hardcoded message if no templates found
+	if textBody == "" {
+		greeting := makeGreeting(displayName)
+		codeMessage := fmt.Sprintf("You have made a request to update your email. Your verification code is %s.", emailAddressVerificationCode)
+		textBody = fmt.Sprintf("%s\n\n%s", greeting, codeMessage)
+	}
+
+	return emailSender.sendToRecipient(displayName, emailAddress, subject, textBody, htmlBody)
+}
+
+func (emailSender *actionsEmailSender) SendUserPasswordResetTemporaryPassword(emailAddress string, displayName string, temporaryPassword string) error {
+	// Broadcast token for testing/automation
+	if emailSender.tokenBroadcaster != nil {
+		emailSender.tokenBroadcaster.BroadcastPasswordReset(emailAddress, temporaryPassword)
+	}
+
+	subject := "Password reset temporary password"
+
+	// mdDisplayName feeds the .md template/greeting only - see the comment in
+	// SendUserEmailAddressUpdateEmailVerificationCode for why.
+	mdDisplayName := escapeMarkdownMetachars(displayName)
+
+	data := map[string]any{
+		"EmailAddress":      escapeMarkdownMetachars(emailAddress),
+		"DisplayName":       mdDisplayName,
+		"TemporaryPassword": temporaryPassword,
+		"Greeting":          makeGreeting(mdDisplayName),
+	}
+
+	// Try to render templates (.txt/.html override, else a single .md source)
+	textBody, htmlBody, err := emailSender.renderBodies("password_reset", data)
+	if err != nil {
+		return err
+	}
+
+	// Fallback to hardcoded message if no templates found
+	if textBody == "" {
+		greeting := makeGreeting(displayName)
+		passwordMessage := fmt.Sprintf("Your password reset temporary password is %s.", temporaryPassword)
+		textBody = fmt.Sprintf("%s\n\n%s", greeting, passwordMessage)
+	}
+
+	return emailSender.sendToRecipient(displayName, emailAddress, subject, textBody, htmlBody)
+}
+
+func (emailSender *actionsEmailSender) SendUserSignedInNotification(emailAddress string, displayName string, sendTime time.Time) error {
+	subject := "Sign-in detected"
+
+	// mdDisplayName feeds the .md template/greeting only - see the comment in
+	// SendUserEmailAddressUpdateEmailVerificationCode for why.
+	mdDisplayName := escapeMarkdownMetachars(displayName)
+
+	data := map[string]any{
+		"EmailAddress": escapeMarkdownMetachars(emailAddress),
+		"DisplayName":  mdDisplayName,
+		"Time":         sendTime.UTC().Format("January 2, 2006 15:04:05"),
+		"Greeting":     makeGreeting(mdDisplayName),
+	}
+
+	// Try to render templates (.txt/.html override, else a single .md source)
+	textBody, htmlBody, err := emailSender.renderBodies("signin_notification", data)
+	if err != nil {
+		return err
+	}
+
+	// Fallback to hardcoded message if no templates found
+	if textBody == "" {
+		greeting := makeGreeting(displayName)
+		notificationMessage := fmt.Sprintf("We detected a sign-in to your account at %s (UTC).", sendTime.UTC().Format("January 2, 2006 15:04:05"))
+		textBody = fmt.Sprintf("%s\n\n%s", greeting, notificationMessage)
+	}
+
+	return emailSender.sendToRecipient(displayName, emailAddress, subject, textBody, htmlBody, emailSender.unsubscribeHeaders(emailAddress, "signin")...)
+}
+
+func (emailSender *actionsEmailSender) SendUserPasswordUpdatedNotification(emailAddress string, displayName string, sendTime time.Time) error {
+	subject := "Password updated"
+
+	// mdDisplayName feeds the .md template/greeting only - see the comment in
+	// SendUserEmailAddressUpdateEmailVerificationCode for why.
+	mdDisplayName := escapeMarkdownMetachars(displayName)
+
+	data := map[string]any{
+		"EmailAddress": escapeMarkdownMetachars(emailAddress),
+		"DisplayName":  mdDisplayName,
+		"Time":         sendTime.UTC().Format("January 2, 2006 15:04:05"),
+		"Greeting":     makeGreeting(mdDisplayName),
+	}
+
+	// Try to render templates (.txt/.html override, else a single .md source)
+	textBody, htmlBody, err := emailSender.renderBodies("password_updated_notification", data)
+	if err != nil {
+		return err
+	}
+
+	// Fallback to hardcoded message if no templates found
+	if textBody == "" {
+		greeting := makeGreeting(displayName)
+		notificationMessage := fmt.Sprintf("Your account password was updated at %s (UTC).", sendTime.UTC().Format("January 2, 2006 15:04:05"))
+		textBody = fmt.Sprintf("%s\n\n%s", greeting, notificationMessage)
+	}
+
+	return emailSender.sendToRecipient(displayName, emailAddress, subject, textBody, htmlBody, emailSender.unsubscribeHeaders(emailAddress, "password_updated")...)
+}
+
+func (emailSender *actionsEmailSender) SendUserEmailAddressUpdatedNotification(emailAddress string, displayName string, newEmailAddress string, sendTime time.Time) error {
+	subject := "Email updated"
+
+	// mdDisplayName feeds the .md template/greeting only - see the comment in
+	// SendUserEmailAddressUpdateEmailVerificationCode for why.
+	mdDisplayName := escapeMarkdownMetachars(displayName)
+
+	data := map[string]any{
+		"EmailAddress":    escapeMarkdownMetachars(emailAddress),
+		"DisplayName":     mdDisplayName,
+		"NewEmailAddress": escapeMarkdownMetachars(newEmailAddress),
+		"Time":            sendTime.UTC().Format("January 2, 2006 15:04:05"),
+		"Greeting":        makeGreeting(mdDisplayName),
+	}
+
+	// Try to render templates (.txt/.html override, else a single .md source)
+	textBody, htmlBody, err := emailSender.renderBodies("email_updated_notification", data)
+	if err != nil {
+		return err
+	}
+
+	// Fallback to hardcoded message if no templates found
+	if textBody == "" {
+		greeting := makeGreeting(displayName)
+		notificationMessage := fmt.Sprintf("Your account email address was updated to %s at %s (UTC).", newEmailAddress, sendTime.UTC().Format("January 2, 2006 15:04:05"))
+		textBody = fmt.Sprintf("%s\n\n%s", greeting, notificationMessage)
+	}
+
+	return emailSender.sendToRecipient(displayName, emailAddress, subject, textBody, htmlBody, emailSender.unsubscribeHeaders(emailAddress, "email_updated")...)
+}
+
+// stdoutEmailSender implements faroe's EmailSenderInterface by logging the rendered
+// message to stdout instead of sending it. Used for local dev and for deployments
+// that only want to observe the token flow (config: email.backend = "stdout"). Unlike
+// the "webhook" backend (webhookEmailClient, which sits behind actionsEmailSender and
+// its templates), this one bypasses templating entirely.
+type stdoutEmailSender struct {
+	tokenBroadcaster *TokenBroadcaster
+}
+
+func (s *stdoutEmailSender) log(kind string, emailAddress string, fields map[string]any) {
+	if s.tokenBroadcaster != nil {
+		switch kind {
+		case "signup_verification":
+			s.tokenBroadcaster.BroadcastSignupVerification(emailAddress, fmt.Sprint(fields["VerificationCode"]))
+		case "email_update_verification":
+			s.tokenBroadcaster.BroadcastEmailUpdateVerification(emailAddress, fmt.Sprint(fields["VerificationCode"]))
+		case "password_reset":
+			s.tokenBroadcaster.BroadcastPasswordReset(emailAddress, fmt.Sprint(fields["TemporaryPassword"]))
+		}
+	}
+
+	log.Printf("[email:%s] to=%s %v", kind, emailAddress, fields)
+}
+
+func (s *stdoutEmailSender) SendSignupEmailAddressVerificationCode(emailAddress string, emailAddressVerificationCode string) error {
+	s.log("signup_verification", emailAddress, map[string]any{"VerificationCode": emailAddressVerificationCode})
+	return nil
+}
+
+func (s *stdoutEmailSender) SendUserEmailAddressUpdateEmailVerificationCode(emailAddress string, displayName string, emailAddressVerificationCode string) error {
+	s.log("email_update_verification", emailAddress, map[string]any{"DisplayName": displayName, "VerificationCode": emailAddressVerificationCode})
+	return nil
+}
+
+func (s *stdoutEmailSender) SendUserPasswordResetTemporaryPassword(emailAddress string, displayName string, temporaryPassword string) error {
+	s.log("password_reset", emailAddress, map[string]any{"DisplayName": displayName, "TemporaryPassword": temporaryPassword})
+	return nil
+}
+
+func (s *stdoutEmailSender) SendUserSignedInNotification(emailAddress string, displayName string, sendTime time.Time) error {
+	s.log("signin_notification", emailAddress, map[string]any{"DisplayName": displayName, "Time": sendTime.UTC()})
+	return nil
+}
+
+func (s *stdoutEmailSender) SendUserPasswordUpdatedNotification(emailAddress string, displayName string, sendTime time.Time) error {
+	s.log("password_updated_notification", emailAddress, map[string]any{"DisplayName": displayName, "Time": sendTime.UTC()})
+	return nil
+}
+
+func (s *stdoutEmailSender) SendUserEmailAddressUpdatedNotification(emailAddress string, displayName string, newEmailAddress string, sendTime time.Time) error {
+	s.log("email_updated_notification", emailAddress, map[string]any{"DisplayName": displayName, "NewEmailAddress": newEmailAddress, "Time": sendTime.UTC()})
+	return nil
+}
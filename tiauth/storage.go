@@ -0,0 +1,609 @@
+package tiauth
+
+import (
+	"bufio"
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"github.com/faroedev/faroe"
+	"github.com/mattn/go-sqlite3"
+)
+
+// ErrMainStorageEntryNotFound is returned by [MainStorage] implementations when a key
+// doesn't exist or an optimistic-concurrency counter doesn't match.
+var ErrMainStorageEntryNotFound = errors.New("entry not found in main storage")
+
+// ErrMainStorageEntryAlreadyExists is returned by [MainStorage.Create] when a key
+// already has a value.
+var ErrMainStorageEntryAlreadyExists = errors.New("entry already exists in main storage")
+
+// storageEntry is one raw row of [Storage]'s key/value table - not a decoded "user" or
+// "session" record. faroe.StorageInterface is a pure opaque KV store (see
+// faroe's storage.go): it has no enumeration primitive, and the key naming scheme
+// that distinguishes a session from a signup/signin/password-reset flow is internal
+// to faroe and not exposed to callers. `tiauth export`/`tiauth import` (cmd/main.go)
+// round-trip every row identically rather than pretending to split them by type.
+type storageEntry struct {
+	Key        string `json:"key"`
+	Value      string `json:"value"` // base64-encoded
+	Counter    int32  `json:"counter"`
+	Expiration string `json:"expiration"` // RFC3339
+}
+
+// storageDumper is implemented by [Storage] backends that can enumerate their own
+// rows for `tiauth export`/`tiauth import`. Only sqliteStorage does today; etcd and
+// postgres would need their own table/range scan to support it.
+type storageDumper interface {
+	dumpEntries() ([]storageEntry, error)
+	loadEntries(entries []storageEntry) error
+}
+
+// Storage is implemented by every storage backend tiauth-faroe can run against.
+// It satisfies [faroe.StorageInterface] and adds Clear so the /reset endpoint and
+// the interactive shell can wipe an instance without knowing the concrete backend.
+type Storage interface {
+	faroe.StorageInterface
+	Clear() error
+	Close()
+}
+
+// MainStorage is a separate key/value store kept alongside [Storage] for data that
+// isn't part of faroe's action state machine (e.g. admin-side bookkeeping).
+// Set overwrites unconditionally; Create and Update are both counter-gated, mirroring
+// faroe.StorageInterface's Add/Update split: Create fails with
+// ErrMainStorageEntryAlreadyExists if the key already exists, so a caller that needs
+// to initialize a record exactly once under concurrency can't silently overwrite
+// another goroutine's write the way Set would.
+type MainStorage interface {
+	Get(key string) ([]byte, int32, error)
+	Set(key string, value []byte, expiresAt time.Time) error
+	Create(key string, value []byte, expiresAt time.Time) error
+	Update(key string, value []byte, expiresAt time.Time, counter int32) error
+	Delete(key string) error
+	Close()
+}
+
+// storageFactory builds a [Storage] from config for a named backend.
+type storageFactory func(cfg Config) (Storage, error)
+
+// mainStorageFactory builds a [MainStorage] from config for a named backend.
+type mainStorageFactory func(cfg Config) (MainStorage, error)
+
+var storageBackends = map[string]storageFactory{
+	"sqlite3":  newSqliteStorage,
+	"etcd":     newEtcdStorage,
+	"postgres": newPostgresStorage,
+}
+
+var mainStorageBackends = map[string]mainStorageFactory{
+	"sqlite3":  newSqliteMainStorage,
+	"etcd":     newEtcdMainStorage,
+	"postgres": newPostgresMainStorage,
+}
+
+// NewStorage builds the [Storage] backend selected by cfg.StorageBackend (default "sqlite3").
+func NewStorage(cfg Config) (Storage, error) {
+	backend := cfg.StorageBackend
+	if backend == "" {
+		backend = "sqlite3"
+	}
+	factory, ok := storageBackends[backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+	return factory(cfg)
+}
+
+// ExportStorage writes every row of storage's key/value table to outPath as one JSON
+// object per line (see storageEntry - a raw key/value/counter/expiration tuple, not a
+// decoded user or session record). Used by `tiauth export`; see cmd/main.go.
+func ExportStorage(storage Storage, outPath string) error {
+	dumper, ok := storage.(storageDumper)
+	if !ok {
+		return fmt.Errorf("export is not supported for this storage backend")
+	}
+	entries, err := dumper.dumpEntries()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(outPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %v", outPath, err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, entry := range entries {
+		if err := enc.Encode(entry); err != nil {
+			return fmt.Errorf("failed to write entry for key %q: %v", entry.Key, err)
+		}
+	}
+	return nil
+}
+
+// ImportStorage reads the JSON-lines format [ExportStorage] writes from inPath and
+// loads each entry into storage via Add, so importing into a non-empty DB fails on
+// the first key collision (faroe.ErrStorageEntryAlreadyExists) instead of silently
+// overwriting existing state. Used by `tiauth import`; see cmd/main.go.
+func ImportStorage(storage Storage, inPath string) error {
+	dumper, ok := storage.(storageDumper)
+	if !ok {
+		return fmt.Errorf("import is not supported for this storage backend")
+	}
+
+	f, err := os.Open(inPath)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", inPath, err)
+	}
+	defer f.Close()
+
+	var entries []storageEntry
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+		var entry storageEntry
+		if err := json.Unmarshal(line, &entry); err != nil {
+			return fmt.Errorf("failed to parse entry: %v", err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read %s: %v", inPath, err)
+	}
+
+	return dumper.loadEntries(entries)
+}
+
+// NewMainStorage builds the [MainStorage] backend selected by cfg.StorageBackend (default "sqlite3").
+func NewMainStorage(cfg Config) (MainStorage, error) {
+	backend := cfg.StorageBackend
+	if backend == "" {
+		backend = "sqlite3"
+	}
+	factory, ok := mainStorageBackends[backend]
+	if !ok {
+		return nil, fmt.Errorf("unknown storage backend %q", backend)
+	}
+	return factory(cfg)
+}
+
+// sqliteStorage implements [Storage] on top of a SQLite key/value table.
+type sqliteStorage struct {
+	db         *sql.DB
+	getStmt    *sql.Stmt
+	addStmt    *sql.Stmt
+	updateStmt *sql.Stmt
+	deleteStmt *sql.Stmt
+}
+
+func newSqliteStorage(cfg Config) (Storage, error) {
+	db, err := sql.Open("sqlite3", cfg.DBPath)
+	if err != nil {
+		return nil, err
+	}
+
+	// WAL mode is faster and more modern (https://sqlite.org/wal.html)
+	// temp_store MEMORY will put more in memory vs in files
+	// synchronous NORMAL still has full integrity when using WAL and is recommended in that case
+	// 64MB cache size vs 8MB default
+	setOptionsStmt := `
+		PRAGMA journal_mode = WAL;
+		PRAGMA temp_store = MEMORY;
+		PRAGMA synchronous = NORMAL;
+		PRAGMA cache_size = -64000;
+	`
+	if _, err := db.Exec(setOptionsStmt); err != nil {
+		return nil, fmt.Errorf("%q: %s", err, setOptionsStmt)
+	}
+
+	createTableStmt := `
+		CREATE TABLE IF NOT EXISTS key_value (
+			key TEXT PRIMARY KEY,
+			counter INTEGER NOT NULL,
+			expiration TEXT NOT NULL,
+			value BLOB NOT NULL
+		) STRICT;
+	`
+	if _, err := db.Exec(createTableStmt); err != nil {
+		return nil, fmt.Errorf("%q: %s", err, createTableStmt)
+	}
+
+	getStmt, err := db.Prepare("SELECT value, counter FROM key_value WHERE key = ?")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare get statement: %v", err)
+	}
+
+	addStmt, err := db.Prepare(`
+		INSERT INTO key_value (key, value, counter, expiration) VALUES (?, ?, 0, ?)
+	`)
+	if err != nil {
+		getStmt.Close()
+		return nil, fmt.Errorf("failed to prepare add statement: %v", err)
+	}
+
+	updateStmt, err := db.Prepare(`
+		UPDATE key_value
+		SET value = ?, counter = counter + 1, expiration = ?
+		WHERE key = ? AND counter = ?
+	`)
+	if err != nil {
+		getStmt.Close()
+		addStmt.Close()
+		return nil, fmt.Errorf("failed to prepare update statement: %v", err)
+	}
+
+	deleteStmt, err := db.Prepare("DELETE FROM key_value WHERE key = ?")
+	if err != nil {
+		getStmt.Close()
+		addStmt.Close()
+		updateStmt.Close()
+		return nil, fmt.Errorf("failed to prepare delete statement: %v", err)
+	}
+
+	return &sqliteStorage{
+		db:         db,
+		getStmt:    getStmt,
+		addStmt:    addStmt,
+		updateStmt: updateStmt,
+		deleteStmt: deleteStmt,
+	}, nil
+}
+
+func (storage *sqliteStorage) Close() {
+	if storage.getStmt != nil {
+		storage.getStmt.Close()
+	}
+	if storage.addStmt != nil {
+		storage.addStmt.Close()
+	}
+	if storage.updateStmt != nil {
+		storage.updateStmt.Close()
+	}
+	if storage.deleteStmt != nil {
+		storage.deleteStmt.Close()
+	}
+	if storage.db != nil {
+		if err := storage.db.Close(); err != nil {
+			log.Printf("Error closing database: %v", err)
+		}
+	}
+}
+
+func (storage *sqliteStorage) Get(key string) ([]byte, int32, error) {
+	var value []byte
+	var counter int32
+
+	err := storage.getStmt.QueryRow(key).Scan(&value, &counter)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, 0, faroe.ErrStorageEntryNotFound
+		}
+		return nil, 0, err
+	}
+
+	return value, counter, nil
+}
+
+func (storage *sqliteStorage) Add(key string, value []byte, expiresAt time.Time) error {
+	expirationStr := expiresAt.Format(time.RFC3339)
+	_, err := storage.addStmt.Exec(key, value, expirationStr)
+	if sqliteErr, ok := err.(sqlite3.Error); ok {
+		if sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+			return faroe.ErrStorageEntryAlreadyExists
+		}
+	}
+	return err
+}
+
+func (storage *sqliteStorage) Update(key string, value []byte, expiresAt time.Time, counter int32) error {
+	expirationStr := expiresAt.Format(time.RFC3339)
+
+	result, err := storage.updateStmt.Exec(value, expirationStr, key, counter)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return faroe.ErrStorageEntryNotFound
+	}
+
+	return nil
+}
+
+func (storage *sqliteStorage) Delete(key string) error {
+	result, err := storage.deleteStmt.Exec(key)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return faroe.ErrStorageEntryNotFound
+	}
+
+	return nil
+}
+
+func (storage *sqliteStorage) Clear() error {
+	_, err := storage.db.Exec("DELETE FROM key_value")
+	return err
+}
+
+// dumpEntries reads every row of the key_value table directly - faroe's
+// StorageInterface has no range/list operation, so this bypasses it rather than
+// extending it.
+func (storage *sqliteStorage) dumpEntries() ([]storageEntry, error) {
+	rows, err := storage.db.Query("SELECT key, value, counter, expiration FROM key_value")
+	if err != nil {
+		return nil, fmt.Errorf("failed to query key_value: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []storageEntry
+	for rows.Next() {
+		var key, expiration string
+		var value []byte
+		var counter int32
+		if err := rows.Scan(&key, &value, &counter, &expiration); err != nil {
+			return nil, fmt.Errorf("failed to scan key_value row: %v", err)
+		}
+		entries = append(entries, storageEntry{
+			Key:        key,
+			Value:      base64.StdEncoding.EncodeToString(value),
+			Counter:    counter,
+			Expiration: expiration,
+		})
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read key_value rows: %v", err)
+	}
+	return entries, nil
+}
+
+// loadEntries inserts entries in a single transaction - so a bad row partway through a
+// large import doesn't leave the DB half-populated and unable to retry after the fix
+// (Add() would reject the already-inserted keys as duplicates) - preserving the
+// original counter directly rather than going through Add() (which always starts a
+// key at counter 0), so a reimported entry round-trips exactly as exported.
+func (storage *sqliteStorage) loadEntries(entries []storageEntry) error {
+	tx, err := storage.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin import transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	insertStmt, err := tx.Prepare(`
+		INSERT INTO key_value (key, value, counter, expiration) VALUES (?, ?, ?, ?)
+	`)
+	if err != nil {
+		return fmt.Errorf("failed to prepare import statement: %v", err)
+	}
+	defer insertStmt.Close()
+
+	for _, entry := range entries {
+		value, err := base64.StdEncoding.DecodeString(entry.Value)
+		if err != nil {
+			return fmt.Errorf("failed to decode value for key %q: %v", entry.Key, err)
+		}
+		if _, err := time.Parse(time.RFC3339, entry.Expiration); err != nil {
+			return fmt.Errorf("failed to parse expiration for key %q: %v", entry.Key, err)
+		}
+		if _, err := insertStmt.Exec(entry.Key, value, entry.Counter, entry.Expiration); err != nil {
+			if sqliteErr, ok := err.(sqlite3.Error); ok && sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+				return fmt.Errorf("failed to import key %q: %v", entry.Key, faroe.ErrStorageEntryAlreadyExists)
+			}
+			return fmt.Errorf("failed to import key %q: %v", entry.Key, err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit import transaction: %v", err)
+	}
+	return nil
+}
+
+// sqliteMainStorage implements [MainStorage] on top of a SQLite key/value table.
+type sqliteMainStorage struct {
+	db         *sql.DB
+	getStmt    *sql.Stmt
+	setStmt    *sql.Stmt
+	createStmt *sql.Stmt
+	updateStmt *sql.Stmt
+	deleteStmt *sql.Stmt
+}
+
+func newSqliteMainStorage(cfg Config) (MainStorage, error) {
+	db, err := sql.Open("sqlite3", cfg.MainDBPath)
+	if err != nil {
+		return nil, err
+	}
+
+	setOptionsStmt := `
+		PRAGMA journal_mode = WAL;
+		PRAGMA temp_store = MEMORY;
+		PRAGMA synchronous = NORMAL;
+		PRAGMA cache_size = -64000;
+	`
+	if _, err := db.Exec(setOptionsStmt); err != nil {
+		return nil, fmt.Errorf("%q: %s", err, setOptionsStmt)
+	}
+
+	createTableStmt := `
+		CREATE TABLE IF NOT EXISTS key_value (
+			key TEXT PRIMARY KEY,
+			counter INTEGER NOT NULL,
+			expiration TEXT NOT NULL,
+			value BLOB NOT NULL
+		) STRICT;
+	`
+	if _, err := db.Exec(createTableStmt); err != nil {
+		return nil, fmt.Errorf("%q: %s", err, createTableStmt)
+	}
+
+	getStmt, err := db.Prepare("SELECT value, counter FROM key_value WHERE key = ?")
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare get statement: %v", err)
+	}
+
+	setStmt, err := db.Prepare(`
+		INSERT OR REPLACE INTO key_value (key, value, counter, expiration)
+		VALUES (?, ?, 0, ?)
+	`)
+	if err != nil {
+		getStmt.Close()
+		return nil, fmt.Errorf("failed to prepare set statement: %v", err)
+	}
+
+	createStmt, err := db.Prepare(`
+		INSERT INTO key_value (key, value, counter, expiration)
+		VALUES (?, ?, 0, ?)
+	`)
+	if err != nil {
+		getStmt.Close()
+		setStmt.Close()
+		return nil, fmt.Errorf("failed to prepare create statement: %v", err)
+	}
+
+	updateStmt, err := db.Prepare(`
+		UPDATE key_value
+		SET value = ?, counter = counter + 1, expiration = ?
+		WHERE key = ? AND counter = ?
+	`)
+	if err != nil {
+		getStmt.Close()
+		setStmt.Close()
+		createStmt.Close()
+		return nil, fmt.Errorf("failed to prepare update statement: %v", err)
+	}
+
+	deleteStmt, err := db.Prepare("DELETE FROM key_value WHERE key = ?")
+	if err != nil {
+		getStmt.Close()
+		setStmt.Close()
+		createStmt.Close()
+		updateStmt.Close()
+		return nil, fmt.Errorf("failed to prepare delete statement: %v", err)
+	}
+
+	return &sqliteMainStorage{
+		db:         db,
+		getStmt:    getStmt,
+		setStmt:    setStmt,
+		createStmt: createStmt,
+		updateStmt: updateStmt,
+		deleteStmt: deleteStmt,
+	}, nil
+}
+
+func (mainStorage *sqliteMainStorage) Close() {
+	if mainStorage.getStmt != nil {
+		mainStorage.getStmt.Close()
+	}
+	if mainStorage.setStmt != nil {
+		mainStorage.setStmt.Close()
+	}
+	if mainStorage.createStmt != nil {
+		mainStorage.createStmt.Close()
+	}
+	if mainStorage.updateStmt != nil {
+		mainStorage.updateStmt.Close()
+	}
+	if mainStorage.deleteStmt != nil {
+		mainStorage.deleteStmt.Close()
+	}
+	if mainStorage.db != nil {
+		if err := mainStorage.db.Close(); err != nil {
+			log.Printf("Error closing database: %v", err)
+		}
+	}
+}
+
+func (mainStorage *sqliteMainStorage) Get(key string) ([]byte, int32, error) {
+	var value []byte
+	var counter int32
+
+	err := mainStorage.getStmt.QueryRow(key).Scan(&value, &counter)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, 0, ErrMainStorageEntryNotFound
+		}
+		return nil, 0, err
+	}
+
+	return value, counter, nil
+}
+
+func (mainStorage *sqliteMainStorage) Set(key string, value []byte, expiresAt time.Time) error {
+	expirationStr := expiresAt.Format(time.RFC3339)
+	_, err := mainStorage.setStmt.Exec(key, value, expirationStr)
+	return err
+}
+
+func (mainStorage *sqliteMainStorage) Create(key string, value []byte, expiresAt time.Time) error {
+	expirationStr := expiresAt.Format(time.RFC3339)
+	_, err := mainStorage.createStmt.Exec(key, value, expirationStr)
+	if sqliteErr, ok := err.(sqlite3.Error); ok {
+		if sqliteErr.ExtendedCode == sqlite3.ErrConstraintPrimaryKey {
+			return ErrMainStorageEntryAlreadyExists
+		}
+	}
+	return err
+}
+
+func (mainStorage *sqliteMainStorage) Update(key string, value []byte, expiresAt time.Time, counter int32) error {
+	expirationStr := expiresAt.Format(time.RFC3339)
+
+	result, err := mainStorage.updateStmt.Exec(value, expirationStr, key, counter)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrMainStorageEntryNotFound
+	}
+
+	return nil
+}
+
+func (mainStorage *sqliteMainStorage) Delete(key string) error {
+	result, err := mainStorage.deleteStmt.Exec(key)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rowsAffected == 0 {
+		return ErrMainStorageEntryNotFound
+	}
+
+	return nil
+}
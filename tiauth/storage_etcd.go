@@ -0,0 +1,320 @@
+package tiauth
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/faroedev/faroe"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+// etcdStorage implements [Storage] on top of etcd, using the mod-revision of a key
+// as its optimistic-concurrency counter and a lease keyed off expiresAt for expiration.
+type etcdStorage struct {
+	client    *clientv3.Client
+	namespace string
+}
+
+// etcdMainStorage implements [MainStorage] on top of etcd.
+type etcdMainStorage struct {
+	client    *clientv3.Client
+	namespace string
+}
+
+func newEtcdClient(cfg Config) (*clientv3.Client, error) {
+	if len(cfg.EtcdEndpoints) == 0 {
+		return nil, fmt.Errorf("etcd storage backend requires at least one endpoint (FAROE_ETCD_ENDPOINTS)")
+	}
+
+	clientConfig := clientv3.Config{
+		Endpoints:   cfg.EtcdEndpoints,
+		DialTimeout: 5 * time.Second,
+	}
+
+	if cfg.EtcdCAFile != "" || cfg.EtcdCertFile != "" || cfg.EtcdKeyFile != "" {
+		tlsConfig, err := etcdTLSConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		clientConfig.TLS = tlsConfig
+	}
+
+	return clientv3.New(clientConfig)
+}
+
+func etcdTLSConfig(cfg Config) (*tls.Config, error) {
+	tlsConfig := &tls.Config{ServerName: cfg.EtcdServerName}
+
+	if cfg.EtcdCertFile != "" && cfg.EtcdKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.EtcdCertFile, cfg.EtcdKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load etcd client cert/key: %v", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if cfg.EtcdCAFile != "" {
+		caBytes, err := os.ReadFile(cfg.EtcdCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read etcd CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caBytes) {
+			return nil, fmt.Errorf("failed to parse etcd CA file %s", cfg.EtcdCAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
+
+func newEtcdStorage(cfg Config) (Storage, error) {
+	client, err := newEtcdClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &etcdStorage{client: client, namespace: cfg.EtcdNamespace}, nil
+}
+
+func newEtcdMainStorage(cfg Config) (MainStorage, error) {
+	client, err := newEtcdClient(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &etcdMainStorage{client: client, namespace: cfg.EtcdNamespace}, nil
+}
+
+func (storage *etcdStorage) prefixedKey(key string) string {
+	return strings.TrimSuffix(storage.namespace, "/") + "/" + key
+}
+
+func (storage *etcdStorage) Close() {
+	storage.client.Close()
+}
+
+func (storage *etcdStorage) Get(key string) ([]byte, int32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := storage.client.Get(ctx, storage.prefixedKey(key))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, 0, faroe.ErrStorageEntryNotFound
+	}
+
+	kv := resp.Kvs[0]
+	return kv.Value, int32(kv.ModRevision), nil
+}
+
+func (storage *etcdStorage) Add(key string, value []byte, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	leaseID, err := storage.grantLease(ctx, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	fullKey := storage.prefixedKey(key)
+	txn := storage.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(fullKey), "=", 0)).
+		Then(clientv3.OpPut(fullKey, string(value), clientv3.WithLease(leaseID)))
+
+	resp, err := txn.Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return faroe.ErrStorageEntryAlreadyExists
+	}
+
+	return nil
+}
+
+func (storage *etcdStorage) Update(key string, value []byte, expiresAt time.Time, counter int32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	leaseID, err := storage.grantLease(ctx, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	fullKey := storage.prefixedKey(key)
+	txn := storage.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(fullKey), "=", int64(counter))).
+		Then(clientv3.OpPut(fullKey, string(value), clientv3.WithLease(leaseID)))
+
+	resp, err := txn.Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return faroe.ErrStorageEntryNotFound
+	}
+
+	return nil
+}
+
+func (storage *etcdStorage) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := storage.client.Delete(ctx, storage.prefixedKey(key))
+	if err != nil {
+		return err
+	}
+	if resp.Deleted == 0 {
+		return faroe.ErrStorageEntryNotFound
+	}
+
+	return nil
+}
+
+func (storage *etcdStorage) Clear() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	prefix := strings.TrimSuffix(storage.namespace, "/") + "/"
+	_, err := storage.client.Delete(ctx, prefix, clientv3.WithPrefix())
+	return err
+}
+
+// grantLease grants a lease that expires at expiresAt, rounded up to the nearest second
+// since etcd leases are specified in whole seconds.
+func (storage *etcdStorage) grantLease(ctx context.Context, expiresAt time.Time) (clientv3.LeaseID, error) {
+	ttl := int64(time.Until(expiresAt).Seconds()) + 1
+	if ttl < 1 {
+		ttl = 1
+	}
+
+	lease, err := storage.client.Grant(ctx, ttl)
+	if err != nil {
+		return 0, fmt.Errorf("failed to grant etcd lease: %v", err)
+	}
+
+	return lease.ID, nil
+}
+
+func (mainStorage *etcdMainStorage) prefixedKey(key string) string {
+	return strings.TrimSuffix(mainStorage.namespace, "/") + "/" + key
+}
+
+func (mainStorage *etcdMainStorage) Close() {
+	mainStorage.client.Close()
+}
+
+func (mainStorage *etcdMainStorage) Get(key string) ([]byte, int32, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := mainStorage.client.Get(ctx, mainStorage.prefixedKey(key))
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(resp.Kvs) == 0 {
+		return nil, 0, ErrMainStorageEntryNotFound
+	}
+
+	kv := resp.Kvs[0]
+	return kv.Value, int32(kv.ModRevision), nil
+}
+
+func (mainStorage *etcdMainStorage) Set(key string, value []byte, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	leaseID, err := mainStorage.grantLease(ctx, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	_, err = mainStorage.client.Put(ctx, mainStorage.prefixedKey(key), string(value), clientv3.WithLease(leaseID))
+	return err
+}
+
+func (mainStorage *etcdMainStorage) Create(key string, value []byte, expiresAt time.Time) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	leaseID, err := mainStorage.grantLease(ctx, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	fullKey := mainStorage.prefixedKey(key)
+	txn := mainStorage.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(fullKey), "=", 0)).
+		Then(clientv3.OpPut(fullKey, string(value), clientv3.WithLease(leaseID)))
+
+	resp, err := txn.Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrMainStorageEntryAlreadyExists
+	}
+
+	return nil
+}
+
+func (mainStorage *etcdMainStorage) Update(key string, value []byte, expiresAt time.Time, counter int32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	leaseID, err := mainStorage.grantLease(ctx, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	fullKey := mainStorage.prefixedKey(key)
+	txn := mainStorage.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(fullKey), "=", int64(counter))).
+		Then(clientv3.OpPut(fullKey, string(value), clientv3.WithLease(leaseID)))
+
+	resp, err := txn.Commit()
+	if err != nil {
+		return err
+	}
+	if !resp.Succeeded {
+		return ErrMainStorageEntryNotFound
+	}
+
+	return nil
+}
+
+func (mainStorage *etcdMainStorage) Delete(key string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	resp, err := mainStorage.client.Delete(ctx, mainStorage.prefixedKey(key))
+	if err != nil {
+		return err
+	}
+	if resp.Deleted == 0 {
+		return ErrMainStorageEntryNotFound
+	}
+
+	return nil
+}
+
+func (mainStorage *etcdMainStorage) grantLease(ctx context.Context, expiresAt time.Time) (clientv3.LeaseID, error) {
+	ttl := int64(time.Until(expiresAt).Seconds()) + 1
+	if ttl < 1 {
+		ttl = 1
+	}
+
+	lease, err := mainStorage.client.Grant(ctx, ttl)
+	if err != nil {
+		return 0, fmt.Errorf("failed to grant etcd lease: %v", err)
+	}
+
+	return lease.ID, nil
+}
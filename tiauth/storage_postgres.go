@@ -0,0 +1,223 @@
+package tiauth
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/faroedev/faroe"
+	_ "github.com/lib/pq"
+)
+
+// postgresStorage implements [Storage] on top of Postgres, mirroring the sqlite3
+// backend's key_value table and counter-based optimistic concurrency.
+type postgresStorage struct {
+	db *sql.DB
+}
+
+// postgresMainStorage implements [MainStorage] on top of Postgres.
+type postgresMainStorage struct {
+	db *sql.DB
+}
+
+const postgresStorageSchema = `
+	CREATE TABLE IF NOT EXISTS key_value (
+		key TEXT PRIMARY KEY,
+		counter INTEGER NOT NULL DEFAULT 0,
+		expiration TIMESTAMPTZ NOT NULL,
+		value BYTEA NOT NULL
+	);
+`
+
+func newPostgresDB(cfg Config) (*sql.DB, error) {
+	if cfg.PostgresDSN == "" {
+		return nil, fmt.Errorf("postgres storage backend requires a DSN (FAROE_POSTGRES_DSN)")
+	}
+
+	db, err := sql.Open("postgres", cfg.PostgresDSN)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := db.Exec(postgresStorageSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("failed to create key_value table: %v", err)
+	}
+
+	return db, nil
+}
+
+func newPostgresStorage(cfg Config) (Storage, error) {
+	db, err := newPostgresDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresStorage{db: db}, nil
+}
+
+func newPostgresMainStorage(cfg Config) (MainStorage, error) {
+	db, err := newPostgresDB(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &postgresMainStorage{db: db}, nil
+}
+
+func (storage *postgresStorage) Close() {
+	storage.db.Close()
+}
+
+func (storage *postgresStorage) Get(key string) ([]byte, int32, error) {
+	var value []byte
+	var counter int32
+
+	err := storage.db.QueryRow("SELECT value, counter FROM key_value WHERE key = $1", key).Scan(&value, &counter)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, 0, faroe.ErrStorageEntryNotFound
+		}
+		return nil, 0, err
+	}
+
+	return value, counter, nil
+}
+
+func (storage *postgresStorage) Add(key string, value []byte, expiresAt time.Time) error {
+	_, err := storage.db.Exec(
+		"INSERT INTO key_value (key, value, counter, expiration) VALUES ($1, $2, 0, $3)",
+		key, value, expiresAt,
+	)
+	if isPostgresUniqueViolation(err) {
+		return faroe.ErrStorageEntryAlreadyExists
+	}
+	return err
+}
+
+func (storage *postgresStorage) Update(key string, value []byte, expiresAt time.Time, counter int32) error {
+	result, err := storage.db.Exec(
+		"UPDATE key_value SET value = $1, counter = counter + 1, expiration = $2 WHERE key = $3 AND counter = $4",
+		value, expiresAt, key, counter,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return faroe.ErrStorageEntryNotFound
+	}
+
+	return nil
+}
+
+func (storage *postgresStorage) Delete(key string) error {
+	result, err := storage.db.Exec("DELETE FROM key_value WHERE key = $1", key)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return faroe.ErrStorageEntryNotFound
+	}
+
+	return nil
+}
+
+func (storage *postgresStorage) Clear() error {
+	_, err := storage.db.Exec("DELETE FROM key_value")
+	return err
+}
+
+func (mainStorage *postgresMainStorage) Close() {
+	mainStorage.db.Close()
+}
+
+func (mainStorage *postgresMainStorage) Get(key string) ([]byte, int32, error) {
+	var value []byte
+	var counter int32
+
+	err := mainStorage.db.QueryRow("SELECT value, counter FROM key_value WHERE key = $1", key).Scan(&value, &counter)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, 0, ErrMainStorageEntryNotFound
+		}
+		return nil, 0, err
+	}
+
+	return value, counter, nil
+}
+
+func (mainStorage *postgresMainStorage) Set(key string, value []byte, expiresAt time.Time) error {
+	_, err := mainStorage.db.Exec(`
+		INSERT INTO key_value (key, value, counter, expiration) VALUES ($1, $2, 0, $3)
+		ON CONFLICT (key) DO UPDATE SET value = $2, counter = 0, expiration = $3
+	`, key, value, expiresAt)
+	return err
+}
+
+func (mainStorage *postgresMainStorage) Create(key string, value []byte, expiresAt time.Time) error {
+	_, err := mainStorage.db.Exec(
+		"INSERT INTO key_value (key, value, counter, expiration) VALUES ($1, $2, 0, $3)",
+		key, value, expiresAt,
+	)
+	if isPostgresUniqueViolation(err) {
+		return ErrMainStorageEntryAlreadyExists
+	}
+	return err
+}
+
+func (mainStorage *postgresMainStorage) Update(key string, value []byte, expiresAt time.Time, counter int32) error {
+	result, err := mainStorage.db.Exec(
+		"UPDATE key_value SET value = $1, counter = counter + 1, expiration = $2 WHERE key = $3 AND counter = $4",
+		value, expiresAt, key, counter,
+	)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrMainStorageEntryNotFound
+	}
+
+	return nil
+}
+
+func (mainStorage *postgresMainStorage) Delete(key string) error {
+	result, err := mainStorage.db.Exec("DELETE FROM key_value WHERE key = $1", key)
+	if err != nil {
+		return err
+	}
+
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return ErrMainStorageEntryNotFound
+	}
+
+	return nil
+}
+
+// isPostgresUniqueViolation reports whether err is a Postgres unique-constraint violation
+// (SQLSTATE 23505), without importing lib/pq's error type into the public API.
+func isPostgresUniqueViolation(err error) bool {
+	type sqlStater interface {
+		SQLState() string
+	}
+	if pqErr, ok := err.(sqlStater); ok {
+		return pqErr.SQLState() == "23505"
+	}
+	return false
+}
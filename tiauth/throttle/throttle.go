@@ -0,0 +1,211 @@
+// Package throttle implements a per-subject exponential backoff used to slow down
+// brute-force and verification-code guessing against faroe's action invocation
+// endpoint, without requiring a shared store (each tiauth process throttles
+// independently, which is fine since it sits directly in front of its own faroe
+// server instance).
+package throttle
+
+import (
+	"hash/fnv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// shardCount is the number of independent map shards. Keeping per-shard locks small
+// means concurrent requests for different subjects rarely contend on the same mutex.
+const shardCount = 32
+
+// entry tracks one (action, subject) key's failure count and the time a later check
+// is next allowed to proceed.
+type entry struct {
+	count         int
+	nextAllowedAt time.Time
+	lastSeen      time.Time
+}
+
+type shard struct {
+	mu      sync.Mutex
+	entries map[string]*entry
+}
+
+// Throttler enforces an exponential backoff per (action, subject) key: the first
+// MaxFree failures are free, after which every further failure doubles the delay
+// before the next attempt is allowed, starting at 1 second and capped at Cap. A
+// success resets the key back to its initial state. Entries that haven't been seen
+// within Window are treated as fresh (and are eventually evicted by the sweeper).
+type Throttler struct {
+	MaxFree int
+	Window  time.Duration
+	Cap     time.Duration
+
+	shards [shardCount]*shard
+
+	stopCh chan struct{}
+	doneCh chan struct{}
+}
+
+// New returns a Throttler with the given grace-attempt count, rolling window, and
+// backoff ceiling. It does not start the sweeper goroutine; call Start for that.
+func New(maxFree int, window time.Duration, cap time.Duration) *Throttler {
+	t := &Throttler{
+		MaxFree: maxFree,
+		Window:  window,
+		Cap:     cap,
+	}
+	for i := range t.shards {
+		t.shards[i] = &shard{entries: make(map[string]*entry)}
+	}
+	return t
+}
+
+func (t *Throttler) shardFor(key string) *shard {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return t.shards[h.Sum32()%shardCount]
+}
+
+func key(action, subject string) string {
+	return action + "\x00" + subject
+}
+
+// Check reports whether an action for subject may proceed right now. If not, it
+// returns the duration the caller should wait before the next attempt.
+func (t *Throttler) Check(action, subject string) (allowed bool, retryAfter time.Duration) {
+	s := t.shardFor(key(action, subject))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	e, ok := s.entries[key(action, subject)]
+	if !ok {
+		return true, 0
+	}
+
+	now := time.Now()
+	if now.Before(e.nextAllowedAt) {
+		return false, e.nextAllowedAt.Sub(now)
+	}
+	return true, 0
+}
+
+// RecordFailure registers a failed attempt for (action, subject), growing the next
+// allowed-at timestamp exponentially once MaxFree free failures have been used up.
+func (t *Throttler) RecordFailure(action, subject string) {
+	k := key(action, subject)
+	s := t.shardFor(k)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	e, ok := s.entries[k]
+	if !ok || (t.Window > 0 && now.Sub(e.lastSeen) > t.Window) {
+		e = &entry{}
+		s.entries[k] = e
+	}
+
+	e.count++
+	e.lastSeen = now
+
+	if e.count > t.MaxFree {
+		backoffExponent := e.count - t.MaxFree - 1
+		e.nextAllowedAt = now.Add(backoffDuration(backoffExponent, t.Cap))
+	}
+}
+
+// backoffDuration returns min(cap, 1s<<exponent), saturating to cap instead of
+// overflowing if exponent is large enough to shift out of range.
+func backoffDuration(exponent int, cap time.Duration) time.Duration {
+	if exponent < 0 {
+		exponent = 0
+	}
+	if exponent > 62 {
+		return cap
+	}
+	d := time.Second << uint(exponent)
+	if d <= 0 || d > cap {
+		return cap
+	}
+	return d
+}
+
+// RecordSuccess clears any throttle state for (action, subject), so the next failure
+// starts the backoff schedule from the beginning again.
+func (t *Throttler) RecordSuccess(action, subject string) {
+	k := key(action, subject)
+	s := t.shardFor(k)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, k)
+}
+
+// ResetMatching clears every entry whose subject contains substr, across all actions
+// and shards, and returns the number of entries cleared. Subjects are the composite
+// "token@IP" this package keys on (see throttleKey in http_server.go) - there's no
+// index from an email address back to one, so an operator clearing an account stuck
+// in backoff must match on the flow token or the client IP instead.
+func (t *Throttler) ResetMatching(substr string) int {
+	cleared := 0
+	for _, s := range t.shards {
+		s.mu.Lock()
+		for k := range s.entries {
+			if strings.Contains(k, substr) {
+				delete(s.entries, k)
+				cleared++
+			}
+		}
+		s.mu.Unlock()
+	}
+	return cleared
+}
+
+// Start launches the background sweeper goroutine, which periodically evicts entries
+// that have been idle for longer than Window so the map does not grow unbounded under
+// a stream of distinct subjects (e.g. distinct attacker-controlled tokens/IPs).
+func (t *Throttler) Start(sweepInterval time.Duration) {
+	if t.stopCh != nil {
+		return
+	}
+	t.stopCh = make(chan struct{})
+	t.doneCh = make(chan struct{})
+
+	go func() {
+		defer close(t.doneCh)
+		ticker := time.NewTicker(sweepInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				t.sweep()
+			case <-t.stopCh:
+				return
+			}
+		}
+	}()
+}
+
+// Stop halts the sweeper goroutine started by Start and waits for it to exit.
+func (t *Throttler) Stop() {
+	if t.stopCh == nil {
+		return
+	}
+	close(t.stopCh)
+	<-t.doneCh
+	t.stopCh = nil
+}
+
+func (t *Throttler) sweep() {
+	cutoff := t.Window
+	if cutoff <= 0 {
+		cutoff = time.Minute
+	}
+	now := time.Now()
+	for _, s := range t.shards {
+		s.mu.Lock()
+		for k, e := range s.entries {
+			if now.Sub(e.lastSeen) > cutoff {
+				delete(s.entries, k)
+			}
+		}
+		s.mu.Unlock()
+	}
+}
@@ -0,0 +1,94 @@
+package throttle
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponentialSchedule(t *testing.T) {
+	th := New(0, time.Minute, 10*time.Second)
+
+	expected := []time.Duration{1 * time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second}
+	for i, want := range expected {
+		th.RecordFailure("verify_signin_user_password", "subject")
+
+		allowed, retryAfter := th.Check("verify_signin_user_password", "subject")
+		if allowed {
+			t.Fatalf("failure %d: expected not allowed", i+1)
+		}
+		if retryAfter <= 0 || retryAfter > want+50*time.Millisecond {
+			t.Fatalf("failure %d: retryAfter = %v, want ~%v", i+1, retryAfter, want)
+		}
+	}
+}
+
+func TestMaxFreeAttemptsAreNotThrottled(t *testing.T) {
+	th := New(2, time.Minute, 10*time.Second)
+
+	th.RecordFailure("action", "subject")
+	th.RecordFailure("action", "subject")
+
+	allowed, retryAfter := th.Check("action", "subject")
+	if !allowed {
+		t.Fatalf("expected free attempts to remain allowed, got retryAfter=%v", retryAfter)
+	}
+
+	th.RecordFailure("action", "subject")
+	allowed, _ = th.Check("action", "subject")
+	if allowed {
+		t.Fatalf("expected throttling to begin after MaxFree failures")
+	}
+}
+
+func TestResetOnSuccess(t *testing.T) {
+	th := New(0, time.Minute, 10*time.Second)
+
+	th.RecordFailure("action", "subject")
+	th.RecordFailure("action", "subject")
+
+	if allowed, _ := th.Check("action", "subject"); allowed {
+		t.Fatalf("expected throttled before success")
+	}
+
+	th.RecordSuccess("action", "subject")
+
+	allowed, _ := th.Check("action", "subject")
+	if !allowed {
+		t.Fatalf("expected success to clear throttle state")
+	}
+
+	// Schedule should restart from the first backoff step, not continue from where
+	// it left off before the reset.
+	th.RecordFailure("action", "subject")
+	_, retryAfter := th.Check("action", "subject")
+	if retryAfter > 1*time.Second+50*time.Millisecond {
+		t.Fatalf("expected schedule to restart at ~1s after reset, got %v", retryAfter)
+	}
+}
+
+func TestSubjectsAreIndependent(t *testing.T) {
+	th := New(0, time.Minute, 10*time.Second)
+
+	th.RecordFailure("action", "subject-a")
+
+	if allowed, _ := th.Check("action", "subject-b"); !allowed {
+		t.Fatalf("expected unrelated subject to be unaffected")
+	}
+}
+
+func TestSweepEvictsColdEntries(t *testing.T) {
+	th := New(0, 20*time.Millisecond, 10*time.Second)
+	th.RecordFailure("action", "subject")
+
+	time.Sleep(40 * time.Millisecond)
+	th.sweep()
+
+	for _, s := range th.shards {
+		s.mu.Lock()
+		n := len(s.entries)
+		s.mu.Unlock()
+		if n != 0 {
+			t.Fatalf("expected sweep to evict cold entries, found %d remaining", n)
+		}
+	}
+}
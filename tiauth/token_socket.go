@@ -11,10 +11,11 @@ import (
 
 // TokenMessage represents a token event sent over the socket
 type TokenMessage struct {
-	Type      string `json:"type"`
-	Email     string `json:"email"`
-	Code      string `json:"code,omitempty"`
-	Timestamp string `json:"timestamp"`
+	Type              string `json:"type"`
+	Email             string `json:"email"`
+	Code              string `json:"code,omitempty"`
+	RetryAfterSeconds int64  `json:"retry_after_seconds,omitempty"`
+	Timestamp         string `json:"timestamp"`
 }
 
 // TokenBroadcaster manages a Unix domain socket that broadcasts token events
@@ -179,6 +180,16 @@ func (tb *TokenBroadcaster) BroadcastPasswordReset(email, temporaryPassword stri
 	})
 }
 
+// BroadcastPasswordResetDenied broadcasts that a password reset request was denied
+// by the password-reset cooldown/quota throttle, instead of a temporary password.
+func (tb *TokenBroadcaster) BroadcastPasswordResetDenied(email string, retryAfter time.Duration) {
+	tb.Broadcast(TokenMessage{
+		Type:              "password_reset_denied",
+		Email:             email,
+		RetryAfterSeconds: int64(retryAfter.Seconds()),
+	})
+}
+
 // Close shuts down the broadcaster
 func (tb *TokenBroadcaster) Close() error {
 	if tb.listener == nil {
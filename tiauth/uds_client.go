@@ -9,6 +9,7 @@ import (
 	"net"
 	"net/http"
 	"sync"
+	"time"
 )
 
 // UDSClient provides HTTP communication over Unix domain sockets.
@@ -17,12 +18,61 @@ type UDSClient struct {
 	socketPath string
 	client     *http.Client
 	mu         sync.Mutex
+
+	ctx      context.Context
+	timeout  time.Duration
+	security TransportSecurity
+
+	readDeadline  *deadlineTimer
+	writeDeadline *deadlineTimer
 }
 
-// NewUDSClient creates a new HTTP client for Unix domain sockets.
-func NewUDSClient(socketPath string) *UDSClient {
+// NewUDSClient creates a new HTTP client for Unix domain sockets. ctx is the parent
+// shutdown context and timeout bounds each individual request (defaults to
+// defaultRequestTimeout when zero), mirroring [NewBackendClient]. Since a Unix socket
+// never leaves the host, security only applies HMAC signing (mTLS fields are ignored)
+// as defense-in-depth against other local users.
+func NewUDSClient(ctx context.Context, socketPath string, timeout time.Duration, security TransportSecurity) *UDSClient {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout <= 0 {
+		timeout = defaultRequestTimeout
+	}
+
 	return &UDSClient{
-		socketPath: socketPath,
+		socketPath:    socketPath,
+		ctx:           ctx,
+		timeout:       timeout,
+		security:      security,
+		readDeadline:  newDeadlineTimer(),
+		writeDeadline: newDeadlineTimer(),
+	}
+}
+
+// SetReadDeadline bounds the time a caller is willing to wait for a response body to
+// be read. It applies to the next call(s) until a new deadline is set.
+func (c *UDSClient) SetReadDeadline(t time.Time) {
+	c.readDeadline.set(t)
+}
+
+// SetWriteDeadline bounds the time a caller is willing to wait for a request to be sent.
+// It applies to the next call(s) until a new deadline is set.
+func (c *UDSClient) SetWriteDeadline(t time.Time) {
+	c.writeDeadline.set(t)
+}
+
+// requestContext derives a context bounded by c.timeout, c.ctx's cancellation, and the
+// most recently set read/write deadlines.
+func (c *UDSClient) requestContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(c.ctx, c.timeout)
+	ctx, cancelWrite := withCancelCh(ctx, c.writeDeadline.get())
+	ctx, cancelRead := withCancelCh(ctx, c.readDeadline.get())
+
+	return ctx, func() {
+		cancelRead()
+		cancelWrite()
+		cancel()
 	}
 }
 
@@ -48,12 +98,16 @@ func (c *UDSClient) ensureClient() {
 func (c *UDSClient) SendActionInvocationEndpointRequest(requestJSON string) (string, error) {
 	c.ensureClient()
 
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
 	// Use "http://uds" as a placeholder - the actual connection goes through the UDS
-	req, err := http.NewRequest("POST", "http://uds/invoke", bytes.NewReader([]byte(requestJSON)))
+	req, err := http.NewRequestWithContext(ctx, "POST", "http://uds/invoke", bytes.NewReader([]byte(requestJSON)))
 	if err != nil {
 		return "", fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	c.security.applySignature(req, "POST", "/invoke", requestJSON)
 
 	resp, err := c.client.Do(req)
 	if err != nil {
@@ -78,6 +132,9 @@ func (c *UDSClient) SendActionInvocationEndpointRequest(requestJSON string) (str
 func (c *UDSClient) SendTestNotification(action, email, code string) error {
 	c.ensureClient()
 
+	ctx, cancel := c.requestContext()
+	defer cancel()
+
 	payload := map[string]string{
 		"action": action,
 		"email":  email,
@@ -88,11 +145,12 @@ func (c *UDSClient) SendTestNotification(action, email, code string) error {
 		return fmt.Errorf("failed to marshal token data: %w", err)
 	}
 
-	req, err := http.NewRequest("POST", "http://uds/token", bytes.NewReader(jsonData))
+	req, err := http.NewRequestWithContext(ctx, "POST", "http://uds/token", bytes.NewReader(jsonData))
 	if err != nil {
 		return fmt.Errorf("failed to create token request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
+	c.security.applySignature(req, "POST", "/token", string(jsonData))
 
 	resp, err := c.client.Do(req)
 	if err != nil {